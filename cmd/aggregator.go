@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// aggregator computes one metric of the response. Every counting worker adds its deliveries to its own
+// aggregators, which are then merged into the first worker's ones before writing their result.
+type aggregator interface {
+	add(r *deliveryRecord)
+	// merge adds up an aggregator of the same metric, which is not used afterwards.
+	merge(o aggregator)
+	result(response *recipeCountResponse)
+}
+
+// deliveryRecord is a delivery as given to aggregators, scanning its delivery window once for all of them.
+type deliveryRecord struct {
+	recipeDelivery
+	window    deliveryWindow
+	hasWindow bool
+	scanned   bool
+}
+
+func (r *deliveryRecord) deliveryWindow() (deliveryWindow, bool) {
+	if !r.scanned {
+		r.window, r.hasWindow = scanDeliveryWindow(r.Delivery)
+		r.scanned = true
+	}
+	return r.window, r.hasWindow
+}
+
+// metric registers an aggregator, computed when enabled by its options or named with -metrics.
+// Metrics without enabledBy are only enabled by name, and metrics without newAggregator are computed
+// by the aggregators of other metrics, from their merged counts. Metrics with an option can't be named
+// without it, as their aggregator has nothing to compute from. Metrics that are exact with the given options,
+// keeping counts per postcode or recipe, can't be used with approx, whose memory must not grow with them.
+type metric struct {
+	name          string
+	summary       string
+	option        string
+	enabledBy     func(options recipeCountOptions) bool
+	newAggregator func(options recipeCountOptions) aggregator
+	exact         func(options recipeCountOptions) bool
+}
+
+func always(recipeCountOptions) bool {
+	return true
+}
+
+// metrics lists every metric, in the order their aggregators run.
+var metrics = []metric{
+	{"recipes", "unique recipe count, count per recipe and matches by name", "", always, newRecipeAggregator, nil},
+	{"postcodes", "busiest postcode and count per postcode and time", "", always, newPostcodeAggregator, nil},
+	{"queries", "count per postcode and time of every query, enabled by -queries", "-queries", func(o recipeCountOptions) bool { return len(o.queries) > 0 }, newQueryAggregator, nil},
+	{"groups", "count per group, enabled by -group-by", "-group-by", func(o recipeCountOptions) bool { return o.groupBy.enabled() }, newGroupAggregator, func(o recipeCountOptions) bool { return o.groupBy.byPostcodeOrRecipe() }},
+	{"catalog", "count per canonical recipe and category, enabled by -catalog", "-catalog", func(o recipeCountOptions) bool { return o.catalog != nil }, newCatalogAggregator, always},
+	{"peaks", "peak concurrent delivery windows per postcode, enabled by -peak-postcodes", "-peak-postcodes", func(o recipeCountOptions) bool { return o.peaks.enabled }, newPeakAggregator, func(o recipeCountOptions) bool { return o.peaks.all() }},
+	{"capacity", "overbooked delivery slots and utilization, enabled by -capacity", "-capacity", func(o recipeCountOptions) bool { return o.capacity != nil }, newCapacityAggregator, nil},
+	{"busiest", "busiest postcodes per weekday and start hour, and busiest weekday and start hour of the busiest postcode", "", nil, newBusiestAggregator, always},
+	{"windows", "delivery window widths per postcode and overall, and count per window", "", nil, newWindowWidthAggregator, always},
+	{metricDistribution, "distribution of deliveries per postcode and recipe, from the postcodes and recipes counts", "", nil, nil, always},
+	{metricCompliance, "deliveries within time for every postcode, from the postcodes counts", "", nil, nil, always},
+}
+
+// metrics computed by the aggregators of other metrics
+const (
+	metricDistribution string = "distribution"
+	metricCompliance   string = "compliance"
+)
+
+func metricNames() []string {
+	names := make([]string, len(metrics))
+	for i, m := range metrics {
+		names[i] = m.name
+	}
+	return names
+}
+
+// parseMetricsOptions checks the names of the metrics to enable on top of the ones enabled by default.
+func parseMetricsOptions(names string) ([]string, error) {
+	enabled := make([]string, 0)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+		if _, ok := findMetric(name); !ok {
+			return nil, fmt.Errorf("unknown metric %q, expected any of: %s", name, strings.Join(metricNames(), ","))
+		}
+		enabled = append(enabled, name)
+	}
+	return enabled, nil
+}
+
+// checkMetrics fails when metrics are enabled by name without the option they need,
+// or when metrics keeping exact counts per postcode or recipe are enabled in approx mode.
+func checkMetrics(options recipeCountOptions) error {
+	for _, m := range metrics {
+		if options.named(m.name) && m.option != "" && !m.enabledBy(options) {
+			return fmt.Errorf("metric %s needs %s", m.name, m.option)
+		}
+		if options.approx.enabled && m.enabled(options) && m.exact != nil && m.exact(options) {
+			return fmt.Errorf("metric %s keeps exact counts per postcode or recipe, and can't be used with approx", m.name)
+		}
+	}
+	return nil
+}
+
+func findMetric(name string) (metric, bool) {
+	for _, m := range metrics {
+		if m.name == name {
+			return m, true
+		}
+	}
+	return metric{}, false
+}
+
+func (m metric) enabled(options recipeCountOptions) bool {
+	return m.enabledBy != nil && m.enabledBy(options) || options.named(m.name)
+}
+
+// named reports whether the metric was enabled by name.
+func (o recipeCountOptions) named(metric string) bool {
+	for _, name := range o.metrics {
+		if name == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// newAggregators returns one new aggregator per enabled metric, always in the same order.
+func newAggregators(options recipeCountOptions) []aggregator {
+	aggregators := make([]aggregator, 0, len(metrics))
+	for _, m := range metrics {
+		if m.newAggregator != nil && m.enabled(options) {
+			aggregators = append(aggregators, m.newAggregator(options))
+		}
+	}
+	return aggregators
+}
+
+// aggregate adds the deliveries matching options.where to every aggregator. It stops early when ctx is done,
+// leaving the aggregators with the counts so far and returning ctx's error.
+func aggregate(ctx context.Context, recipeDeliveryInput []recipeDelivery, options recipeCountOptions, aggregators []aggregator) error {
+	// a single record is reused, as handing a new one to the aggregators would allocate it for every delivery
+	var record deliveryRecord
+	reported := 0
+	for i, r := range recipeDeliveryInput {
+		if i%cancelCheckInterval == 0 {
+			options.stats.addRecordsCounted(i - reported)
+			reported = i
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		record = deliveryRecord{recipeDelivery: r}
+		if !options.where.matches(&record) {
+			continue
+		}
+		for _, a := range aggregators {
+			a.add(&record)
+		}
+	}
+
+	options.stats.addRecordsCounted(len(recipeDeliveryInput) - reported)
+	return nil
+}
+
+// mergeAggregators merges every partial aggregator into the total one of the same metric.
+func mergeAggregators(total []aggregator, partial []aggregator) {
+	for i := range total {
+		total[i].merge(partial[i])
+	}
+}
+
+func buildAggregatedResponse(aggregators []aggregator) recipeCountResponse {
+	var response recipeCountResponse
+	for _, a := range aggregators {
+		a.result(&response)
+	}
+	return response
+}
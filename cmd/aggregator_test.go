@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMetricsOptions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should parse metric names": func(t *testing.T) {
+			// when
+			names, err := parseMetricsOptions(" Groups, ,recipes")
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"groups", "recipes"}, names)
+		},
+		"should not parse unknown metric names": func(t *testing.T) {
+			// when
+			_, err := parseMetricsOptions("recipes,colours")
+
+			// then
+			assert.Error(t, err)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestCheckMetrics(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should check metrics named with the options they need": func(t *testing.T) {
+			// given
+			groupBy, _ := parseGroupByOptions("weekday", groupSortCount, 0)
+			peaks, _ := parsePeakOptions("10120")
+
+			// when
+			err := checkMetrics(recipeCountOptions{metrics: []string{"groups", "peaks", "windows"}, groupBy: groupBy, peaks: peaks})
+
+			// then
+			assert.NoError(t, err)
+		},
+		"should not check metrics named without the options they need": func(t *testing.T) {
+			// then
+			for _, name := range []string{"groups", "catalog", "peaks", "capacity"} {
+				err := checkMetrics(recipeCountOptions{metrics: []string{name}})
+				assert.Error(t, err, name)
+			}
+		},
+		"should not check exact metrics in approx mode": func(t *testing.T) {
+			// given
+			approx, _ := parseApproxOptions(true, 10, 0.01, 0.01, 3)
+			groupBy, _ := parseGroupByOptions("weekday,postcode", groupSortCount, 0)
+			catalog, _ := newRecipeCatalog(nil)
+			peaks, _ := parsePeakOptions("*")
+
+			// then
+			for _, name := range []string{metricDistribution, metricCompliance, "busiest", "windows"} {
+				err := checkMetrics(recipeCountOptions{metrics: []string{name}, approx: approx})
+				assert.Error(t, err, name)
+			}
+			assert.Error(t, checkMetrics(recipeCountOptions{groupBy: groupBy, approx: approx}))
+			assert.Error(t, checkMetrics(recipeCountOptions{catalog: catalog, approx: approx}))
+			assert.Error(t, checkMetrics(recipeCountOptions{peaks: peaks, approx: approx}))
+		},
+		"should check metrics with bounded counts in approx mode": func(t *testing.T) {
+			// given
+			approx, _ := parseApproxOptions(true, 10, 0.01, 0.01, 3)
+			groupBy, _ := parseGroupByOptions("weekday,hour", groupSortCount, 0)
+			peaks, _ := parsePeakOptions("10120")
+			queries, _ := parsePostcodeQueries("10208", deliveryPeriod{})
+
+			// when
+			err := checkMetrics(recipeCountOptions{groupBy: groupBy, peaks: peaks, queries: queries, approx: approx})
+
+			// then
+			assert.NoError(t, err)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestNewAggregators(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should enable default metrics only": func(t *testing.T) {
+			// when
+			aggregators := newAggregators(recipeCountOptions{})
+
+			// then
+			assert.Len(t, aggregators, 2)
+			assert.IsType(t, &recipeAggregator{}, aggregators[0])
+			assert.IsType(t, &postcodeAggregator{}, aggregators[1])
+		},
+		"should enable metrics by options and by name": func(t *testing.T) {
+			// given
+			groupBy, _ := parseGroupByOptions("weekday", groupSortCount, 0)
+			approx, _ := parseApproxOptions(true, 10, 0.01, 0.01, 3)
+
+			// when
+			byOptions := newAggregators(recipeCountOptions{groupBy: groupBy, approx: approx})
+			byName := newAggregators(recipeCountOptions{metrics: []string{"windows"}})
+
+			// then
+			assert.Len(t, byOptions, 3)
+			assert.IsType(t, &approxRecipeAggregator{}, byOptions[0])
+			assert.IsType(t, &approxPostcodeAggregator{}, byOptions[1])
+			assert.IsType(t, &groupAggregator{}, byOptions[2])
+			assert.Len(t, byName, 3)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 11AM - 2PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 9AM - 2PM"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should aggregate, merge and build the response": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("demo.json", "10120", "10AM-3PM", "Steak")
+
+			// when
+			response := aggregateInTwoWorkers(t, options, input, 1)
+
+			// then
+			assert.Equal(t, 2, response.UniqueRecipeCount)
+			assert.Equal(t, postcodeCount{Postcode: "10120", Found: true, DeliveryCount: 2}, response.BusiestPostcode)
+			assert.Equal(t, 1, response.CountPerPostcodeTime.DeliveryCount)
+			assert.Equal(t, []string{"Speedy Steak Fajitas"}, response.MatchByName)
+			assert.Nil(t, response.CountPerGroup)
+		},
+		"should scan the delivery window once per record": func(t *testing.T) {
+			// given
+			record := deliveryRecord{recipeDelivery: input[0]}
+
+			// when
+			window, ok := record.deliveryWindow()
+			record.Delivery = "Someday"
+			cached, cachedOk := record.deliveryWindow()
+
+			// then
+			assert.True(t, ok)
+			assert.True(t, cachedOk)
+			assert.Equal(t, window, cached)
+			assert.Equal(t, 2, window.weekday())
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+// aggregateInTwoWorkers aggregates input split at the given index with two sets of aggregators, as two workers
+// would, and builds the response from the merged aggregators.
+func aggregateInTwoWorkers(t *testing.T, options recipeCountOptions, input []recipeDelivery, split int) recipeCountResponse {
+	aggregators, aggregatorsOther := newAggregators(options), newAggregators(options)
+	assert.NoError(t, aggregate(context.Background(), input[:split], options, aggregators))
+	assert.NoError(t, aggregate(context.Background(), input[split:], options, aggregatorsOther))
+	mergeAggregators(aggregators, aggregatorsOther)
+	return buildAggregatedResponse(aggregators)
+}
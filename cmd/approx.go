@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+const approxPrecisionDefault uint = 14
+const approxEpsilonDefault float64 = 0.0001
+const approxDeltaDefault float64 = 0.01
+const approxTopDefault int = 10
+
+type approxOptions struct {
+	enabled   bool
+	precision uint8
+	epsilon   float64
+	delta     float64
+	top       int
+}
+
+func parseApproxOptions(enabled bool, precision uint, epsilon float64, delta float64, top int) (approxOptions, error) {
+	if precision < 4 || precision > 18 {
+		return approxOptions{}, errors.New("approx precision must be between 4 and 18")
+	}
+	if epsilon <= 0 || epsilon >= 1 {
+		return approxOptions{}, errors.New("approx epsilon must be between 0 and 1")
+	}
+	if delta <= 0 || delta >= 1 {
+		return approxOptions{}, errors.New("approx delta must be between 0 and 1")
+	}
+	if top < 1 {
+		return approxOptions{}, errors.New("approx top must be a positive number")
+	}
+
+	return approxOptions{
+		enabled:   enabled,
+		precision: uint8(precision),
+		epsilon:   epsilon,
+		delta:     delta,
+		top:       top,
+	}, nil
+}
+
+// how many recipe names an approxRecipeAggregator caches the match results of
+const matchesCacheSize int = 1 << 16
+
+// approxRecipeAggregator is the bounded-memory counterpart of recipeAggregator, for inputs with too many
+// distinct recipes to be counted exactly. Only recipes matching the searched names are counted exactly.
+type approxRecipeAggregator struct {
+	distinct     *hyperLogLog
+	top          *heavyHitters
+	matches      recipeCountSet
+	matchesCache map[string]bool
+	search       nameSearch
+	collate      string
+	order        recipeOrderOptions
+	delta        float64
+}
+
+func newApproxRecipeAggregator(options recipeCountOptions) *approxRecipeAggregator {
+	return &approxRecipeAggregator{
+		distinct:     newHyperLogLog(options.approx.precision),
+		top:          newHeavyHitters(options.approx.top, options.approx.epsilon, options.approx.delta),
+		matches:      make(recipeCountSet),
+		matchesCache: make(map[string]bool),
+		search:       options.nameSearch(),
+		collate:      options.collate,
+		order:        options.recipeOrder,
+		delta:        options.approx.delta,
+	}
+}
+
+func (a *approxRecipeAggregator) add(r *deliveryRecord) {
+	a.distinct.add(r.Recipe)
+	a.top.add(r.Recipe)
+	a.addMatch(r.Recipe)
+}
+
+// addMatch keeps the exact count of recipes matching the searched names, which are few enough
+// to be held in memory. Match results are cached as the same recipe names repeat across records,
+// up to matchesCacheSize recipes so that the cache doesn't grow with the distinct recipes.
+func (a *approxRecipeAggregator) addMatch(recipe string) {
+	matches, cached := a.matchesCache[recipe]
+	if !cached {
+		matches = a.search.matches(recipe)
+		if len(a.matchesCache) < matchesCacheSize {
+			a.matchesCache[recipe] = matches
+		}
+	}
+	if matches {
+		a.matches.add(recipe)
+	}
+}
+
+func (a *approxRecipeAggregator) merge(o aggregator) {
+	other := o.(*approxRecipeAggregator)
+	a.distinct.merge(other.distinct)
+	a.top.merge(other.top)
+	a.matches.merge(other.matches)
+}
+
+func (a *approxRecipeAggregator) result(response *recipeCountResponse) {
+	response.UniqueRecipeCount = a.distinct.estimate()
+	top := a.top.toSortedList()
+	if a.order.sort == recipeSortName {
+		sort.Slice(top, func(i, j int) bool {
+			return top[i].Recipe < top[j].Recipe
+		})
+		top.collate(a.collate)
+	}
+	response.CountPerRecipe = top.order(a.order)
+	matches := a.matches.toSortedList()
+	matches.collate(a.collate)
+	response.MatchByName = matches.filterByNames(a.search)
+
+	approximation := responseApproximation(response)
+	approximation.UniqueCountRelError = a.distinct.relativeError()
+	approximation.DeliveryCountAbsError = a.top.sketch.absoluteError()
+	approximation.Confidence = 1 - a.delta
+}
+
+// approxPostcodeAggregator is the bounded-memory counterpart of postcodeAggregator, for inputs with too many
+// distinct postcodes to be counted exactly. Only the searched postcode is counted exactly.
+type approxPostcodeAggregator struct {
+	distinct    *hyperLogLog
+	top         *heavyHitters
+	search      postcodeMatches
+	postcode    string
+	delivery    deliveryPeriod
+	searchStart int
+	searchEnd   int
+	delta       float64
+}
+
+func newApproxPostcodeAggregator(options recipeCountOptions) *approxPostcodeAggregator {
+	searchStart, searchEnd := options.delivery.minutes()
+	return &approxPostcodeAggregator{
+		distinct:    newHyperLogLog(options.approx.precision),
+		top:         newHeavyHitters(options.approx.top, options.approx.epsilon, options.approx.delta),
+		postcode:    options.postcode,
+		delivery:    options.delivery,
+		searchStart: searchStart,
+		searchEnd:   searchEnd,
+		delta:       options.approx.delta,
+	}
+}
+
+func (a *approxPostcodeAggregator) add(r *deliveryRecord) {
+	a.distinct.add(r.Postcode)
+	a.top.add(r.Postcode)
+
+	if r.Postcode != a.postcode {
+		return
+	}
+	deliveryWindow, ok := r.deliveryWindow()
+	a.search.deliveryCount++
+	if ok && deliveryWindow.includedIn(a.searchStart, a.searchEnd) {
+		a.search.deliveryWithinTimeCount++
+	}
+}
+
+func (a *approxPostcodeAggregator) merge(o aggregator) {
+	other := o.(*approxPostcodeAggregator)
+	a.distinct.merge(other.distinct)
+	a.top.merge(other.top)
+	a.search.deliveryCount += other.search.deliveryCount
+	a.search.deliveryWithinTimeCount += other.search.deliveryWithinTimeCount
+}
+
+func (a *approxPostcodeAggregator) result(response *recipeCountResponse) {
+	topPostcodes := a.top.toSortedList()
+	response.BusiestPostcode = postcodeCount{}
+	if len(topPostcodes) > 0 {
+		response.BusiestPostcode = postcodeCount{
+			Postcode:      topPostcodes[0].Recipe,
+			Found:         true,
+			DeliveryCount: topPostcodes[0].DeliveryCount,
+		}
+	}
+	response.CountPerPostcodeTime = postcodeTimeCount{
+		Postcode:      a.postcode,
+		Found:         a.search.deliveryCount > 0,
+		From:          a.delivery.start.Format(timestampLayout),
+		To:            a.delivery.end.Format(timestampLayout),
+		DeliveryCount: a.search.deliveryWithinTimeCount,
+	}
+
+	approximation := responseApproximation(response)
+	approximation.UniquePostcodeCount = a.distinct.estimate()
+	approximation.Confidence = 1 - a.delta
+}
+
+// responseApproximation returns the section the sketch-based aggregators report their error bounds in,
+// adding it on the first of them to report.
+func responseApproximation(response *recipeCountResponse) *approximation {
+	if response.Approximation == nil {
+		response.Approximation = &approximation{}
+	}
+	return response.Approximation
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseApproxOptions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should parse approx options": func(t *testing.T) {
+			// when
+			options, err := parseApproxOptions(true, 12, 0.001, 0.05, 5)
+
+			// then
+			assert.Equal(t, approxOptions{enabled: true, precision: 12, epsilon: 0.001, delta: 0.05, top: 5}, options)
+			assert.NoError(t, err)
+		},
+		"should not parse out of range approx options": func(t *testing.T) {
+			// when
+			_, errPrecision := parseApproxOptions(true, 30, 0.001, 0.05, 5)
+			_, errEpsilon := parseApproxOptions(true, 12, 0, 0.05, 5)
+			_, errDelta := parseApproxOptions(true, 12, 0.001, 1, 5)
+			_, errTop := parseApproxOptions(true, 12, 0.001, 0.05, 0)
+
+			// then
+			assert.Error(t, errPrecision)
+			assert.Error(t, errEpsilon)
+			assert.Error(t, errDelta)
+			assert.Error(t, errTop)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestApproxAggregators(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should estimate counts and merge partial counters": func(t *testing.T) {
+			// given
+			deliveryWindow, _ := parseDeliveryPeriod("10AM-3PM")
+			recipeSearch := make(recipeSearchSet)
+			recipeSearch.addBulk("Chicken", ",")
+			approx, _ := parseApproxOptions(true, 14, 0.001, 0.01, 2)
+			options := recipeCountOptions{
+				postcode: "10120",
+				delivery: deliveryWindow,
+				recipes:  recipeSearch,
+				approx:   approx,
+			}
+			input := []recipeDelivery{
+				{Postcode: "10120", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Wednesday 10AM - 3PM"},
+				{Postcode: "10208", Recipe: "Creamy Dill Chicken", Delivery: "Thursday 11AM - 2PM"},
+				{Postcode: "10120", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Thursday 9AM - 3PM"},
+				{Postcode: "10186", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Saturday 1AM - 8PM"},
+				{Postcode: "10120", Recipe: "Hot Honey Barbecue Chicken Legs", Delivery: "Wednesday 10AM - 2PM"},
+			}
+
+			// when
+			response := aggregateInTwoWorkers(t, options, input, 3)
+
+			// then
+			assert.Equal(t, 3, response.UniqueRecipeCount)
+			assert.Equal(t, recipeCountList{
+				{Recipe: "Cherry Balsamic Pork Chops", DeliveryCount: 3},
+				{Recipe: "Creamy Dill Chicken", DeliveryCount: 1},
+			}, response.CountPerRecipe)
+			assert.Equal(t, postcodeCount{Postcode: "10120", Found: true, DeliveryCount: 3}, response.BusiestPostcode)
+			assert.Equal(t, 2, response.CountPerPostcodeTime.DeliveryCount)
+			assert.Equal(t, []string{"Creamy Dill Chicken", "Hot Honey Barbecue Chicken Legs"}, response.MatchByName)
+			assert.Equal(t, 3, response.Approximation.UniquePostcodeCount)
+			assert.Equal(t, 0.99, response.Approximation.Confidence)
+		},
+		"should order top recipes by name when asked to": func(t *testing.T) {
+			// given
+			approx, _ := parseApproxOptions(true, 14, 0.001, 0.01, 2)
+			order, _ := parseRecipeOrderOptions(recipeSortName, 0, 0)
+			options := recipeCountOptions{approx: approx, recipeOrder: order}
+			input := []recipeDelivery{
+				{Postcode: "10120", Recipe: "Speedy Steak Fajitas"},
+				{Postcode: "10120", Recipe: "Creamy Dill Chicken"},
+				{Postcode: "10208", Recipe: "Speedy Steak Fajitas"},
+				{Postcode: "10208", Recipe: "Tex-Mex Tilapia"},
+			}
+			aggregators := newAggregators(options)
+
+			// when
+			err := aggregate(context.Background(), input, options, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, recipeCountList{
+				{Recipe: "Creamy Dill Chicken", DeliveryCount: 1},
+				{Recipe: "Speedy Steak Fajitas", DeliveryCount: 2},
+			}, response.CountPerRecipe)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"sort"
+)
+
+const busiestTopDefault int = 3
+
+const hoursPerDay int = 24
+
+// busiestAggregator counts the deliveries of every postcode per weekday and start hour, to rank postcodes
+// in every weekday and start hour, and to find the busiest weekday and start hour of the busiest postcode.
+type busiestAggregator struct {
+	counts map[postcodeSlot]int
+	top    int
+}
+
+// postcodeSlot is the weekday and start hour of a postcode's deliveries.
+type postcodeSlot struct {
+	postcode string
+	weekday  int
+	hour     int
+}
+
+func newBusiestAggregator(options recipeCountOptions) aggregator {
+	top := options.busiestTop
+	if top == 0 {
+		top = busiestTopDefault
+	}
+	return &busiestAggregator{make(map[postcodeSlot]int), top}
+}
+
+func (a *busiestAggregator) add(r *deliveryRecord) {
+	w, ok := r.deliveryWindow()
+	if !ok {
+		return
+	}
+	a.counts[postcodeSlot{r.Postcode, w.weekday(), w.start % minutesPerDay / 60}]++
+}
+
+func (a *busiestAggregator) merge(o aggregator) {
+	for slot, count := range o.(*busiestAggregator).counts {
+		a.counts[slot] += count
+	}
+}
+
+// result reads the busiest postcode from the response, so that it must come after the postcodes metric.
+func (a *busiestAggregator) result(response *recipeCountResponse) {
+	var perWeekday [len(weekdays)]map[string]int
+	var perHour [hoursPerDay]map[string]int
+	var busiestWeekdays [len(weekdays)]int
+	var busiestHours [hoursPerDay]int
+	busiest := response.BusiestPostcode.Postcode
+	for slot, count := range a.counts {
+		if perWeekday[slot.weekday] == nil {
+			perWeekday[slot.weekday] = make(map[string]int)
+		}
+		perWeekday[slot.weekday][slot.postcode] += count
+		if perHour[slot.hour] == nil {
+			perHour[slot.hour] = make(map[string]int)
+		}
+		perHour[slot.hour][slot.postcode] += count
+		if slot.postcode == busiest {
+			busiestWeekdays[slot.weekday] += count
+			busiestHours[slot.hour] += count
+		}
+	}
+
+	report := &busiestReport{PerWeekday: make([]slotBusiest, 0), PerStartHour: make([]slotBusiest, 0)}
+	for weekday, counts := range perWeekday {
+		if counts != nil {
+			slot := a.rank(counts)
+			slot.Weekday = weekdays[weekday]
+			report.PerWeekday = append(report.PerWeekday, slot)
+		}
+	}
+	for hour, counts := range perHour {
+		if counts != nil {
+			slot := a.rank(counts)
+			slot.StartHour = formatHour(hour)
+			report.PerStartHour = append(report.PerStartHour, slot)
+		}
+	}
+
+	weekday, hour := busiestIndex(busiestWeekdays[:]), busiestIndex(busiestHours[:])
+	if busiest != "" && busiestWeekdays[weekday] > 0 {
+		report.BusiestPostcode = &postcodeBusiestSlots{
+			Postcode:               busiest,
+			Weekday:                weekdays[weekday],
+			WeekdayDeliveryCount:   busiestWeekdays[weekday],
+			StartHour:              formatHour(hour),
+			StartHourDeliveryCount: busiestHours[hour],
+		}
+	}
+	response.Busiest = report
+}
+
+// rank returns the deliveries of a weekday or start hour, along with its top postcodes,
+// ties broken by postcode.
+func (a *busiestAggregator) rank(counts map[string]int) slotBusiest {
+	slot := slotBusiest{TopPostcodes: make([]postcodeDeliveryCount, 0, len(counts))}
+	for postcode, count := range counts {
+		slot.DeliveryCount += count
+		slot.TopPostcodes = append(slot.TopPostcodes, postcodeDeliveryCount{postcode, count})
+	}
+	sort.Slice(slot.TopPostcodes, func(i, j int) bool {
+		if slot.TopPostcodes[i].DeliveryCount != slot.TopPostcodes[j].DeliveryCount {
+			return slot.TopPostcodes[i].DeliveryCount > slot.TopPostcodes[j].DeliveryCount
+		}
+		return slot.TopPostcodes[i].Postcode < slot.TopPostcodes[j].Postcode
+	})
+	if len(slot.TopPostcodes) > a.top {
+		slot.TopPostcodes = slot.TopPostcodes[:a.top]
+	}
+	return slot
+}
+
+// busiestIndex returns the index of the highest count, ties broken by the lowest index.
+func busiestIndex(counts []int) int {
+	busiest := 0
+	for i, count := range counts {
+		if count > counts[busiest] {
+			busiest = i
+		}
+	}
+	return busiest
+}
+
+// busiestReport ranks postcodes per weekday and start hour, only listing those with deliveries.
+type busiestReport struct {
+	PerWeekday      []slotBusiest         `json:"per_weekday"`
+	PerStartHour    []slotBusiest         `json:"per_start_hour"`
+	BusiestPostcode *postcodeBusiestSlots `json:"busiest_postcode,omitempty"`
+}
+
+type slotBusiest struct {
+	Weekday       string                  `json:"weekday,omitempty"`
+	StartHour     string                  `json:"start_hour,omitempty"`
+	DeliveryCount int                     `json:"delivery_count"`
+	TopPostcodes  []postcodeDeliveryCount `json:"top_postcodes"`
+}
+
+type postcodeDeliveryCount struct {
+	Postcode      string `json:"postcode"`
+	DeliveryCount int    `json:"delivery_count"`
+}
+
+type postcodeBusiestSlots struct {
+	Postcode               string `json:"postcode"`
+	Weekday                string `json:"weekday"`
+	WeekdayDeliveryCount   int    `json:"weekday_delivery_count"`
+	StartHour              string `json:"start_hour"`
+	StartHourDeliveryCount int    `json:"start_hour_delivery_count"`
+}
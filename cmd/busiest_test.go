@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusiestAggregator(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 9AM - 11AM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Friday 10AM - 1PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 10AM - 1PM"},
+		{Postcode: "10131", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 9AM - 1PM"},
+		{Postcode: "10131", Recipe: "Speedy Steak Fajitas", Delivery: "Someday"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should rank postcodes per weekday and start hour across workers": func(t *testing.T) {
+			// given
+			options := recipeCountOptions{metrics: []string{"busiest"}, busiestTop: 2}
+
+			// when
+			response := aggregateInTwoWorkers(t, options, input, 2)
+
+			// then
+			assert.Equal(t, &busiestReport{
+				PerWeekday: []slotBusiest{
+					{Weekday: "Wednesday", DeliveryCount: 4, TopPostcodes: []postcodeDeliveryCount{{"10120", 2}, {"10131", 1}}},
+					{Weekday: "Friday", DeliveryCount: 1, TopPostcodes: []postcodeDeliveryCount{{"10120", 1}}},
+				},
+				PerStartHour: []slotBusiest{
+					{StartHour: "9AM", DeliveryCount: 2, TopPostcodes: []postcodeDeliveryCount{{"10120", 1}, {"10131", 1}}},
+					{StartHour: "10AM", DeliveryCount: 3, TopPostcodes: []postcodeDeliveryCount{{"10120", 2}, {"10208", 1}}},
+				},
+				BusiestPostcode: &postcodeBusiestSlots{
+					Postcode:               "10120",
+					Weekday:                "Wednesday",
+					WeekdayDeliveryCount:   2,
+					StartHour:              "10AM",
+					StartHourDeliveryCount: 2,
+				},
+			}, response.Busiest)
+		},
+		"should find the busiest slots of the lowest of tied busiest postcodes": func(t *testing.T) {
+			// given
+			tied := make([]recipeDelivery, 0)
+			for i := 20; i > 0; i-- {
+				delivery := weekdays[i%len(weekdays)] + " " + formatHour(i%12+1) + " - 11PM"
+				tied = append(tied, recipeDelivery{Postcode: generatedPostcode(i), Recipe: "Creamy Dill Chicken", Delivery: delivery})
+			}
+			options := recipeCountOptions{metrics: []string{"busiest"}}
+
+			// when
+			response := aggregateInTwoWorkers(t, options, tied, 10)
+
+			// then
+			assert.Equal(t, postcodeCount{Postcode: generatedPostcode(1), Found: true, DeliveryCount: 1}, response.BusiestPostcode)
+			assert.Equal(t, &postcodeBusiestSlots{
+				Postcode:               generatedPostcode(1),
+				Weekday:                "Tuesday",
+				WeekdayDeliveryCount:   1,
+				StartHour:              "2AM",
+				StartHourDeliveryCount: 1,
+			}, response.Busiest.BusiestPostcode)
+		},
+		"should not find the busiest slots without deliveries": func(t *testing.T) {
+			// given
+			options := recipeCountOptions{metrics: []string{"busiest"}}
+			aggregators := newAggregators(options)
+
+			// when
+			err := aggregate(context.Background(), input[5:], options, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, &busiestReport{PerWeekday: []slotBusiest{}, PerStartHour: []slotBusiest{}}, response.Busiest)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
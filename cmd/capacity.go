@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// capacityEntry caps the deliveries of a postcode or region in an hourly slot, such as "10AM" for 10AM to 11AM.
+type capacityEntry struct {
+	Postcode      string `json:"postcode"`
+	Region        string `json:"region"`
+	Weekday       string `json:"weekday"`
+	Hour          string `json:"hour"`
+	MaxDeliveries int    `json:"max_deliveries"`
+}
+
+// capacitySlotKey is the hourly slot of a postcode or a region, in hours since Monday 12AM.
+type capacitySlotKey struct {
+	postcode string
+	region   string
+	hour     int
+}
+
+// deliveryCapacity holds the max deliveries of every slot, along with the postcodes and regions having any.
+type deliveryCapacity struct {
+	slots     map[capacitySlotKey]int
+	postcodes map[string]bool
+	regions   map[string]bool
+}
+
+func newDeliveryCapacity(entries []capacityEntry) (*deliveryCapacity, error) {
+	c := &deliveryCapacity{make(map[capacitySlotKey]int), make(map[string]bool), make(map[string]bool)}
+	for i, e := range entries {
+		postcode, region := strings.TrimSpace(e.Postcode), strings.TrimSpace(e.Region)
+		if (len(postcode) == 0) == (len(region) == 0) {
+			return nil, fmt.Errorf("capacity %d must have either a postcode or a region", i+1)
+		}
+		weekday := -1
+		for d, name := range weekdays {
+			if strings.EqualFold(name, strings.TrimSpace(e.Weekday)) {
+				weekday = d
+			}
+		}
+		if weekday < 0 {
+			return nil, fmt.Errorf("capacity %d has an unknown weekday %q", i+1, e.Weekday)
+		}
+		hourValue := strings.ToUpper(strings.TrimSpace(e.Hour))
+		hour, end, ok := scanHour(hourValue, 0)
+		if !ok || end != len(hourValue) {
+			return nil, fmt.Errorf("capacity %d has a badly formatted hour %q", i+1, e.Hour)
+		}
+		if e.MaxDeliveries <= 0 {
+			return nil, fmt.Errorf("capacity %d must allow a positive number of deliveries", i+1)
+		}
+
+		key := capacitySlotKey{postcode, region, weekday*24 + hour/60}
+		if _, exists := c.slots[key]; exists {
+			return nil, fmt.Errorf("capacity %d repeats the %s %s slot of %s%s", i+1, weekdays[weekday], formatHour(hour/60), postcode, region)
+		}
+		c.slots[key] = e.MaxDeliveries
+		if len(postcode) > 0 {
+			c.postcodes[postcode] = true
+		} else {
+			c.regions[region] = true
+		}
+	}
+	return c, nil
+}
+
+// needsRegions reports whether any slot is a region's, looked up in the regions given with -regions.
+func (c *deliveryCapacity) needsRegions() bool {
+	return len(c.regions) > 0
+}
+
+// loadDeliveryCapacity reads a CSV capacity file when it has a .csv extension, or a JSON one otherwise.
+// CSV files have a postcode,region,weekday,hour,max_deliveries header, where every row has a postcode or a region.
+func loadDeliveryCapacity(filePath string) (*deliveryCapacity, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []capacityEntry
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		entries, err = decodeCapacityCSV(bytes.NewReader(content))
+	} else {
+		err = json.Unmarshal(content, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("capacity %s: %v", filePath, err)
+	}
+	return newDeliveryCapacity(entries)
+}
+
+func decodeCapacityCSV(r io.Reader) ([]capacityEntry, error) {
+	entries := make([]capacityEntry, 0)
+	var rowErr error
+	err := decodeCSVRows(r, "max_deliveries", func(field func(column string) string) {
+		maxDeliveries, err := strconv.Atoi(field("max_deliveries"))
+		if err != nil && rowErr == nil {
+			rowErr = fmt.Errorf("capacity %d has badly formatted max deliveries %q", len(entries)+1, field("max_deliveries"))
+		}
+		entries = append(entries, capacityEntry{
+			Postcode:      field("postcode"),
+			Region:        field("region"),
+			Weekday:       field("weekday"),
+			Hour:          field("hour"),
+			MaxDeliveries: maxDeliveries,
+		})
+	})
+	if err == nil {
+		err = rowErr
+	}
+	return entries, err
+}
+
+// capacityAggregator counts the deliveries of every capacity slot, where a delivery takes the slot of its postcode,
+// and of its region, at the weekday and hour its window starts.
+type capacityAggregator struct {
+	capacity *deliveryCapacity
+	regions  *postcodeRegions
+	counts   map[capacitySlotKey]int
+}
+
+func newCapacityAggregator(options recipeCountOptions) aggregator {
+	return &capacityAggregator{options.capacity, options.regions, make(map[capacitySlotKey]int)}
+}
+
+func (a *capacityAggregator) add(r *deliveryRecord) {
+	postcode := ""
+	if a.capacity.postcodes[r.Postcode] {
+		postcode = r.Postcode
+	}
+	region := ""
+	if a.capacity.needsRegions() {
+		if found, ok := a.regions.lookup(r.Postcode); ok && a.capacity.regions[found.Region] {
+			region = found.Region
+		}
+	}
+	if len(postcode) == 0 && len(region) == 0 {
+		return
+	}
+	window, ok := r.deliveryWindow()
+	if !ok {
+		return
+	}
+
+	hour := window.start / 60
+	for _, key := range [...]capacitySlotKey{{postcode: postcode, hour: hour}, {region: region, hour: hour}} {
+		if len(key.postcode) == 0 && len(key.region) == 0 {
+			continue
+		}
+		if _, exists := a.capacity.slots[key]; exists {
+			a.counts[key]++
+		}
+	}
+}
+
+func (a *capacityAggregator) merge(o aggregator) {
+	for key, count := range o.(*capacityAggregator).counts {
+		a.counts[key] += count
+	}
+}
+
+func (a *capacityAggregator) result(response *recipeCountResponse) {
+	report := &capacityReport{
+		CountPerSlot:    make([]capacitySlot, 0, len(a.capacity.slots)),
+		OverbookedSlots: make([]capacitySlot, 0),
+	}
+	for key, maxDeliveries := range a.capacity.slots {
+		count := a.counts[key]
+		slot := capacitySlot{
+			Postcode:              key.postcode,
+			Region:                key.region,
+			Weekday:               weekdays[key.hour/24],
+			Hour:                  formatHour(key.hour % 24),
+			MaxDeliveries:         maxDeliveries,
+			DeliveryCount:         count,
+			UtilizationPercentage: utilizationPercentage(count, maxDeliveries),
+			hour:                  key.hour,
+		}
+		if count > maxDeliveries {
+			slot.ExcessDeliveryCount = count - maxDeliveries
+			report.OverbookedSlots = append(report.OverbookedSlots, slot)
+		}
+		report.CountPerSlot = append(report.CountPerSlot, slot)
+		report.MaxDeliveries += maxDeliveries
+		report.DeliveryCount += count
+		report.ExcessDeliveryCount += slot.ExcessDeliveryCount
+	}
+	report.SlotCount = len(report.CountPerSlot)
+	report.OverbookedSlotCount = len(report.OverbookedSlots)
+	report.UtilizationPercentage = utilizationPercentage(report.DeliveryCount, report.MaxDeliveries)
+
+	sort.Slice(report.CountPerSlot, func(i, j int) bool {
+		return report.CountPerSlot[i].before(report.CountPerSlot[j])
+	})
+	sort.Slice(report.OverbookedSlots, func(i, j int) bool {
+		if report.OverbookedSlots[i].ExcessDeliveryCount != report.OverbookedSlots[j].ExcessDeliveryCount {
+			return report.OverbookedSlots[i].ExcessDeliveryCount > report.OverbookedSlots[j].ExcessDeliveryCount
+		}
+		return report.OverbookedSlots[i].before(report.OverbookedSlots[j])
+	})
+	response.Capacity = report
+}
+
+// capacityReport counts deliveries in every capacity slot, where overbooked slots are sorted by excess deliveries.
+// Totals add up every slot, so a delivery taking both a postcode and a region slot counts in both.
+type capacityReport struct {
+	SlotCount             int            `json:"slot_count"`
+	OverbookedSlotCount   int            `json:"overbooked_slot_count"`
+	MaxDeliveries         int            `json:"max_deliveries"`
+	DeliveryCount         int            `json:"delivery_count"`
+	UtilizationPercentage float64        `json:"utilization_percentage"`
+	ExcessDeliveryCount   int            `json:"excess_delivery_count"`
+	OverbookedSlots       []capacitySlot `json:"overbooked_slots"`
+	CountPerSlot          []capacitySlot `json:"count_per_slot"`
+}
+
+type capacitySlot struct {
+	Postcode              string  `json:"postcode,omitempty"`
+	Region                string  `json:"region,omitempty"`
+	Weekday               string  `json:"weekday"`
+	Hour                  string  `json:"hour"`
+	MaxDeliveries         int     `json:"max_deliveries"`
+	DeliveryCount         int     `json:"delivery_count"`
+	UtilizationPercentage float64 `json:"utilization_percentage"`
+	ExcessDeliveryCount   int     `json:"excess_delivery_count"`
+	hour                  int
+}
+
+func utilizationPercentage(count int, maxDeliveries int) float64 {
+	if maxDeliveries == 0 {
+		return 0
+	}
+	return 100 * float64(count) / float64(maxDeliveries)
+}
+
+// before orders postcode slots first, then region slots, each by name and then by hour of the week.
+func (s capacitySlot) before(o capacitySlot) bool {
+	if (len(s.Postcode) > 0) != (len(o.Postcode) > 0) {
+		return len(s.Postcode) > 0
+	}
+	if s.Postcode+s.Region != o.Postcode+o.Region {
+		return s.Postcode+s.Region < o.Postcode+o.Region
+	}
+	return s.hour < o.hour
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDeliveryCapacity(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should load postcode and region slots": func(t *testing.T) {
+			// when
+			capacity, err := loadDeliveryCapacity("../data/capacity.csv")
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, 1, capacity.slots[capacitySlotKey{postcode: "10120", hour: 2*24 + 10}])
+			assert.Equal(t, 2, capacity.slots[capacitySlotKey{region: "North", hour: 3*24 + 10}])
+			assert.True(t, capacity.needsRegions())
+		},
+		"should load JSON slots": func(t *testing.T) {
+			// given
+			filePath := writeConfigFile(t, "capacity.json", `[{"postcode": "10120", "weekday": "sunday", "hour": "12am", "max_deliveries": 3}]`)
+
+			// when
+			capacity, err := loadDeliveryCapacity(filePath)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, 3, capacity.slots[capacitySlotKey{postcode: "10120", hour: 6 * 24}])
+			assert.False(t, capacity.needsRegions())
+		},
+		"should not load invalid slots": func(t *testing.T) {
+			// given
+			noMax := writeConfigFile(t, "no-max.csv", "postcode,weekday,hour\n10120,Monday,9AM\n")
+			badMax := writeConfigFile(t, "bad-max.csv", "postcode,weekday,hour,max_deliveries\n10120,Monday,9AM,many\n")
+			both := writeConfigFile(t, "both.csv", "postcode,region,weekday,hour,max_deliveries\n10120,North,Monday,9AM,1\n")
+			neither := writeConfigFile(t, "neither.csv", "postcode,region,weekday,hour,max_deliveries\n,,Monday,9AM,1\n")
+			weekday := writeConfigFile(t, "weekday.csv", "postcode,weekday,hour,max_deliveries\n10120,Someday,9AM,1\n")
+			hour := writeConfigFile(t, "hour.csv", "postcode,weekday,hour,max_deliveries\n10120,Monday,9AM-10AM,1\n")
+			zero := writeConfigFile(t, "zero.json", `[{"postcode": "10120", "weekday": "Monday", "hour": "9AM", "max_deliveries": 0}]`)
+			repeated := writeConfigFile(t, "repeated.csv", "postcode,weekday,hour,max_deliveries\n10120,Monday,9AM,1\n10120,monday,9 AM,2\n")
+
+			// then
+			for _, filePath := range []string{"../data/missing.csv", noMax, badMax, both, neither, weekday, hour, zero, repeated} {
+				_, err := loadDeliveryCapacity(filePath)
+				assert.Error(t, err, filePath)
+			}
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestCapacityAggregator(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 12PM"},
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 11AM"},
+		{Postcode: "10131", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 11AM - 1PM"},
+		{Postcode: "10131", Recipe: "Speedy Steak Fajitas", Delivery: "Someday"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 11AM - 2PM"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should count deliveries in the postcode and region slots they start in, across workers": func(t *testing.T) {
+			// given
+			regions, _ := newPostcodeRegions([]postcodeRegion{{Postcode: "101*", Region: "North"}, {Postcode: "102*", Region: "South"}})
+			capacity, _ := newDeliveryCapacity([]capacityEntry{
+				{Postcode: "10120", Weekday: "Wednesday", Hour: "10AM", MaxDeliveries: 1},
+				{Postcode: "10120", Weekday: "Wednesday", Hour: "11AM", MaxDeliveries: 4},
+				{Region: "North", Weekday: "Wednesday", Hour: "11AM", MaxDeliveries: 1},
+			})
+			options := recipeCountOptions{regions: regions, capacity: capacity}
+
+			// when
+			response := aggregateInTwoWorkers(t, options, input, 2)
+
+			// then
+			wednesday := 2 * 24
+			assert.Equal(t, &capacityReport{
+				SlotCount:             3,
+				OverbookedSlotCount:   2,
+				MaxDeliveries:         6,
+				DeliveryCount:         5,
+				UtilizationPercentage: 100 * 5.0 / 6,
+				ExcessDeliveryCount:   2,
+				OverbookedSlots: []capacitySlot{
+					{"10120", "", "Wednesday", "10AM", 1, 2, 200, 1, wednesday + 10},
+					{"", "North", "Wednesday", "11AM", 1, 2, 200, 1, wednesday + 11},
+				},
+				CountPerSlot: []capacitySlot{
+					{"10120", "", "Wednesday", "10AM", 1, 2, 200, 1, wednesday + 10},
+					{"10120", "", "Wednesday", "11AM", 4, 1, 25, 0, wednesday + 11},
+					{"", "North", "Wednesday", "11AM", 1, 2, 200, 1, wednesday + 11},
+				},
+			}, response.Capacity)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// separates the aliases and categories of a recipe within a catalog CSV field
+const catalogListSeparator string = "|"
+
+// catalogRecipe is a canonical recipe, known by its name and any of its aliases.
+type catalogRecipe struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Aliases    []string `json:"aliases"`
+	Categories []string `json:"categories"`
+}
+
+// recipeCatalog maps raw recipe names to canonical recipes, ignoring case and surrounding spaces.
+type recipeCatalog struct {
+	recipes []catalogRecipe
+	byName  map[string]int
+}
+
+func catalogKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func newRecipeCatalog(recipes []catalogRecipe) (*recipeCatalog, error) {
+	catalog := &recipeCatalog{recipes, make(map[string]int)}
+	ids := make(map[string]bool)
+	for i, r := range recipes {
+		if len(strings.TrimSpace(r.ID)) == 0 {
+			return nil, fmt.Errorf("catalog recipe %d has no id", i+1)
+		}
+		if ids[r.ID] {
+			return nil, fmt.Errorf("catalog recipe id %q is repeated", r.ID)
+		}
+		ids[r.ID] = true
+		if len(r.Name) == 0 {
+			catalog.recipes[i].Name = r.ID
+		}
+
+		for _, name := range append([]string{r.ID, r.Name}, r.Aliases...) {
+			key := catalogKey(name)
+			if len(key) == 0 {
+				continue
+			}
+			if j, exists := catalog.byName[key]; exists && j != i {
+				return nil, fmt.Errorf("catalog name %q maps to both %q and %q", name, recipes[j].ID, r.ID)
+			}
+			catalog.byName[key] = i
+		}
+	}
+	return catalog, nil
+}
+
+func (c *recipeCatalog) lookup(recipe string) (catalogRecipe, bool) {
+	i, ok := c.byName[catalogKey(recipe)]
+	if !ok {
+		return catalogRecipe{}, false
+	}
+	return c.recipes[i], true
+}
+
+// loadRecipeCatalog reads a CSV catalog when the file has a .csv extension, or a JSON one otherwise.
+// CSV catalogs have an id,name,aliases,categories header, with lists separated by "|".
+func loadRecipeCatalog(filePath string) (*recipeCatalog, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipes []catalogRecipe
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		recipes, err = decodeCatalogCSV(bytes.NewReader(content))
+	} else {
+		err = json.Unmarshal(content, &recipes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("catalog %s: %v", filePath, err)
+	}
+	return newRecipeCatalog(recipes)
+}
+
+func decodeCatalogCSV(r io.Reader) ([]catalogRecipe, error) {
+	recipes := make([]catalogRecipe, 0)
+	err := decodeCSVRows(r, "id", func(field func(column string) string) {
+		recipes = append(recipes, catalogRecipe{
+			ID:         field("id"),
+			Name:       field("name"),
+			Aliases:    splitCatalogList(field("aliases")),
+			Categories: splitCatalogList(field("categories")),
+		})
+	})
+	return recipes, err
+}
+
+// decodeCSVRows reads CSV rows after their header, which must have the required column. The field function
+// given to onRow returns the trimmed value of a column in the row, empty when the header lacks it.
+func decodeCSVRows(r io.Reader, required string, onRow func(field func(column string) string)) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns[required]; !ok {
+		return fmt.Errorf("missing %s column", required)
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		onRow(func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		})
+	}
+}
+
+func splitCatalogList(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, catalogListSeparator) {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// catalogAggregator counts raw recipe names, which are mapped to canonical recipes and categories once merged,
+// so that every distinct name is looked up in the catalog only once.
+type catalogAggregator struct {
+	set     recipeCountSet
+	catalog *recipeCatalog
+}
+
+func newCatalogAggregator(options recipeCountOptions) aggregator {
+	catalog := options.catalog
+	if catalog == nil {
+		catalog, _ = newRecipeCatalog(nil)
+	}
+	return &catalogAggregator{make(recipeCountSet), catalog}
+}
+
+func (a *catalogAggregator) add(r *deliveryRecord) {
+	a.set.add(r.Recipe)
+}
+
+func (a *catalogAggregator) merge(o aggregator) {
+	a.set.merge(o.(*catalogAggregator).set)
+}
+
+func (a *catalogAggregator) result(response *recipeCountResponse) {
+	canonical := make(map[string]int)
+	categories := make(recipeCountSet)
+	unmapped := make(recipeCountSet)
+	for name, count := range a.set {
+		recipe, ok := a.catalog.lookup(name)
+		if !ok {
+			unmapped[name] += count
+			continue
+		}
+		canonical[recipe.ID] += count
+		for _, category := range recipe.Categories {
+			categories[category] += count
+		}
+	}
+
+	report := &catalogReport{
+		CountPerCanonicalRecipe: make([]canonicalRecipeCount, 0, len(canonical)),
+		CountPerCategory:        make([]categoryCount, 0, len(categories)),
+		UnmappedRecipes:         unmapped.toSortedList(),
+	}
+	for _, recipe := range a.catalog.recipes {
+		if count, ok := canonical[recipe.ID]; ok {
+			report.CountPerCanonicalRecipe = append(report.CountPerCanonicalRecipe, canonicalRecipeCount{recipe.ID, recipe.Name, count})
+		}
+	}
+	sort.Slice(report.CountPerCanonicalRecipe, func(i, j int) bool {
+		return report.CountPerCanonicalRecipe[i].ID < report.CountPerCanonicalRecipe[j].ID
+	})
+	for _, c := range categories.toSortedList() {
+		report.CountPerCategory = append(report.CountPerCategory, categoryCount{c.Recipe, c.DeliveryCount})
+	}
+	response.Catalog = report
+}
+
+type catalogReport struct {
+	CountPerCanonicalRecipe []canonicalRecipeCount `json:"count_per_canonical_recipe"`
+	CountPerCategory        []categoryCount        `json:"count_per_category"`
+	UnmappedRecipes         recipeCountList        `json:"unmapped_recipes"`
+}
+
+type canonicalRecipeCount struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	DeliveryCount int    `json:"count"`
+}
+
+type categoryCount struct {
+	Category      string `json:"category"`
+	DeliveryCount int    `json:"count"`
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRecipeCatalog(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should load CSV catalog": func(t *testing.T) {
+			// when
+			catalog, err := loadRecipeCatalog("../data/catalog.csv")
+
+			// then
+			assert.NoError(t, err)
+			recipe, found := catalog.lookup(" speedy steak fajitas (V2)")
+			assert.True(t, found)
+			assert.Equal(t, catalogRecipe{
+				ID:         "steak-fajitas",
+				Name:       "Speedy Steak Fajitas",
+				Aliases:    []string{"Speedy Steak Fajitas (v2)"},
+				Categories: []string{"mexican", "spicy"},
+			}, recipe)
+			_, found = catalog.lookup("Melty Monterey Jack Burgers")
+			assert.False(t, found)
+		},
+		"should load JSON catalog": func(t *testing.T) {
+			// given
+			filePath := writeConfigFile(t, "catalog.json", `[{"id": "fajitas", "aliases": ["Speedy Steak Fajitas"], "categories": ["spicy"]}]`)
+
+			// when
+			catalog, err := loadRecipeCatalog(filePath)
+
+			// then
+			assert.NoError(t, err)
+			recipe, found := catalog.lookup("Speedy Steak Fajitas")
+			assert.True(t, found)
+			assert.Equal(t, "fajitas", recipe.Name)
+		},
+		"should not load invalid catalogs": func(t *testing.T) {
+			// given
+			noID := writeConfigFile(t, "no-id.csv", "name,categories\nSpeedy Steak Fajitas,spicy\n")
+			emptyID := writeConfigFile(t, "empty-id.json", `[{"id": " ", "name": "Speedy Steak Fajitas"}]`)
+			repeatedID := writeConfigFile(t, "repeated-id.json", `[{"id": "fajitas"}, {"id": "fajitas"}]`)
+			ambiguous := writeConfigFile(t, "ambiguous.csv", "id,name,aliases\nsteak,Speedy Steak Fajitas,Fajitas\nmushroom,Speedy Mushroom Fajitas,fajitas\n")
+
+			// when
+			_, errMissing := loadRecipeCatalog("../data/missing.csv")
+			_, errNoID := loadRecipeCatalog(noID)
+			_, errEmptyID := loadRecipeCatalog(emptyID)
+			_, errRepeatedID := loadRecipeCatalog(repeatedID)
+			_, errAmbiguous := loadRecipeCatalog(ambiguous)
+
+			// then
+			assert.Error(t, errMissing)
+			assert.Error(t, errNoID)
+			assert.Error(t, errEmptyID)
+			assert.Error(t, errRepeatedID)
+			assert.Error(t, errAmbiguous)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestCatalogAggregator(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should count per canonical recipe and category, listing unmapped recipes": func(t *testing.T) {
+			// given
+			catalog, _ := newRecipeCatalog([]catalogRecipe{
+				{ID: "steak-fajitas", Name: "Speedy Steak Fajitas", Aliases: []string{"Speedy Steak Fajitas (v2)"}, Categories: []string{"mexican", "spicy"}},
+				{ID: "quesadillas", Name: "Garden Quesadillas", Categories: []string{"mexican", "vegetarian"}},
+				{ID: "tilapia", Name: "Tex-Mex Tilapia"},
+			})
+			options := recipeCountOptions{catalog: catalog}
+			input := []recipeDelivery{
+				{Postcode: "10120", Recipe: "Speedy Steak Fajitas"},
+				{Postcode: "10120", Recipe: "Speedy Steak Fajitas (v2)"},
+				{Postcode: "10208", Recipe: "Garden Quesadillas"},
+				{Postcode: "10208", Recipe: "Melty Monterey Jack Burgers"},
+			}
+			aggregators := newAggregators(options)
+
+			// when
+			err := aggregate(context.Background(), input, options, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, &catalogReport{
+				CountPerCanonicalRecipe: []canonicalRecipeCount{
+					{ID: "quesadillas", Name: "Garden Quesadillas", DeliveryCount: 1},
+					{ID: "steak-fajitas", Name: "Speedy Steak Fajitas", DeliveryCount: 2},
+				},
+				CountPerCategory: []categoryCount{
+					{Category: "mexican", DeliveryCount: 3},
+					{Category: "spicy", DeliveryCount: 2},
+					{Category: "vegetarian", DeliveryCount: 1},
+				},
+				UnmappedRecipes: recipeCountList{{Recipe: "Melty Monterey Jack Burgers", DeliveryCount: 1}},
+			}, response.Catalog)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
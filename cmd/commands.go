@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+const commandDefault string = "count"
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists every subcommand, each parsing its own flags and returning its own exit code.
+// The commands slice is filled in by init, as the help command lists it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"count", "counts the deliveries in a fixtures data file (default)", runCount},
+		{"validate", "checks a fixtures data file and reports its bad records", runValidate},
+		{"recommend-window", "recommends the delivery window including the most deliveries of a postcode", runRecommendWindow},
+		{"generate", "writes reproducible synthetic fixtures", runGenerate},
+		{"config", "prints the effective count configuration, as `config print`", runConfig},
+		{"help", "describes the available commands", runHelp},
+	}
+}
+
+// run dispatches the command line to its subcommand and returns the exit code, logging errors to stderr.
+// Without a subcommand, the arguments are counted as before subcommands existed.
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runCount(args)
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	log.Printf("unknown command %q, see `recipe-count help`", args[0])
+	return exitUsage
+}
+
+func runHelp(args []string) int {
+	printCommands(os.Stdout)
+	return exitOK
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "usage: recipe-count [command] [options]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-17s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nrun `recipe-count <command> -h` for the options of each command.\n")
+}
+
+// setUsage sets the help text of a subcommand's flags, shown on -h or on invalid flags.
+func setUsage(flags *flag.FlagSet, usage string, description string) {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: recipe-count %s\n\n%s\n\noptions:\n", usage, description)
+		flags.PrintDefaults()
+	}
+}
+
+// usageError logs err, unless it's a help request the flags already answered, and returns exitUsage.
+func usageError(err error) int {
+	if err != flag.ErrHelp {
+		log.Println(err)
+	}
+	return exitUsage
+}
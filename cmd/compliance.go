@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sort"
+)
+
+// how many postcodes with the lowest share of deliveries within time are reported
+const complianceLowestCount int = 10
+
+// complianceReport holds, for every postcode, how many of its deliveries fall within the searched time.
+type complianceReport struct {
+	From                    string               `json:"from"`
+	To                      string               `json:"to"`
+	DeliveryCount           int                  `json:"delivery_count"`
+	DeliveryWithinTimeCount int                  `json:"delivery_within_time_count"`
+	WithinTimePercentage    float64              `json:"within_time_percentage"`
+	CountPerPostcode        []postcodeCompliance `json:"count_per_postcode"`
+	LowestCompliance        []postcodeCompliance `json:"lowest_compliance"`
+}
+
+type postcodeCompliance struct {
+	Postcode                string  `json:"postcode"`
+	DeliveryCount           int     `json:"delivery_count"`
+	DeliveryWithinTimeCount int     `json:"delivery_within_time_count"`
+	WithinTimePercentage    float64 `json:"within_time_percentage"`
+}
+
+func withinTimePercentage(withinTime int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(withinTime) / float64(total)
+}
+
+// toComplianceReport lists postcodes by postcode, and the lowest compliance ones by percentage, breaking ties
+// by the most deliveries first, as these are the most significant, and then by postcode.
+func (s postcodeCountSet) toComplianceReport(delivery deliveryPeriod) *complianceReport {
+	report := &complianceReport{
+		From:             delivery.start.Format(timestampLayout),
+		To:               delivery.end.Format(timestampLayout),
+		CountPerPostcode: make([]postcodeCompliance, 0, len(s)),
+	}
+	for postcode, matches := range s {
+		report.DeliveryCount += matches.deliveryCount
+		report.DeliveryWithinTimeCount += matches.deliveryWithinTimeCount
+		report.CountPerPostcode = append(report.CountPerPostcode, postcodeCompliance{
+			Postcode:                postcode,
+			DeliveryCount:           matches.deliveryCount,
+			DeliveryWithinTimeCount: matches.deliveryWithinTimeCount,
+			WithinTimePercentage:    withinTimePercentage(matches.deliveryWithinTimeCount, matches.deliveryCount),
+		})
+	}
+	report.WithinTimePercentage = withinTimePercentage(report.DeliveryWithinTimeCount, report.DeliveryCount)
+	sort.Slice(report.CountPerPostcode, func(i, j int) bool {
+		return report.CountPerPostcode[i].Postcode < report.CountPerPostcode[j].Postcode
+	})
+
+	lowest := make([]postcodeCompliance, len(report.CountPerPostcode))
+	copy(lowest, report.CountPerPostcode)
+	sort.SliceStable(lowest, func(i, j int) bool {
+		if lowest[i].WithinTimePercentage != lowest[j].WithinTimePercentage {
+			return lowest[i].WithinTimePercentage < lowest[j].WithinTimePercentage
+		}
+		return lowest[i].DeliveryCount > lowest[j].DeliveryCount
+	})
+	if len(lowest) > complianceLowestCount {
+		lowest = lowest[:complianceLowestCount]
+	}
+	report.LowestCompliance = lowest
+	return report
+}
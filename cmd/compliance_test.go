@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplianceMetric(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 9AM - 3PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 11AM - 2PM"},
+		{Postcode: "10186", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 1AM - 8PM"},
+		{Postcode: "10186", Recipe: "Speedy Steak Fajitas", Delivery: "Someday"},
+		{Postcode: "10137", Recipe: "Tex-Mex Tilapia", Delivery: "Monday 11AM - 8PM"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should count deliveries within time for every postcode": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("demo.json", "10120", "10AM-3PM", "")
+			options.metrics, _ = parseMetricsOptions("compliance")
+			aggregators := newAggregators(options)
+
+			// when
+			err := aggregate(context.Background(), input, options, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, 1, response.CountPerPostcodeTime.DeliveryCount)
+			report := response.Compliance
+			assert.Equal(t, "10AM", report.From)
+			assert.Equal(t, 6, report.DeliveryCount)
+			assert.Equal(t, 2, report.DeliveryWithinTimeCount)
+			assert.InDelta(t, 33.33, report.WithinTimePercentage, 0.01)
+			assert.Equal(t, []postcodeCompliance{
+				{Postcode: "10120", DeliveryCount: 2, DeliveryWithinTimeCount: 1, WithinTimePercentage: 50},
+				{Postcode: "10137", DeliveryCount: 1, DeliveryWithinTimeCount: 0, WithinTimePercentage: 0},
+				{Postcode: "10186", DeliveryCount: 2, DeliveryWithinTimeCount: 0, WithinTimePercentage: 0},
+				{Postcode: "10208", DeliveryCount: 1, DeliveryWithinTimeCount: 1, WithinTimePercentage: 100},
+			}, report.CountPerPostcode)
+			lowest := make([]string, 0)
+			for _, c := range report.LowestCompliance {
+				lowest = append(lowest, c.Postcode)
+			}
+			assert.Equal(t, []string{"10186", "10137", "10120", "10208"}, lowest)
+		},
+		"should only check the searched postcode by default": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("demo.json", "10120", "10AM-3PM", "")
+
+			// when
+			aggregators := newAggregators(options)
+			err := aggregate(context.Background(), input, options, aggregators)
+			postcodeSet := aggregators[1].(*postcodeAggregator).set
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, 0, postcodeSet["10208"].deliveryWithinTimeCount)
+			assert.Equal(t, 1, postcodeSet["10120"].deliveryWithinTimeCount)
+		},
+		"should keep the lowest compliance postcodes only": func(t *testing.T) {
+			// given
+			set := make(postcodeCountSet)
+			for i := 0; i < 2*complianceLowestCount; i++ {
+				set.add(generatedPostcode(i), i%2 == 0)
+			}
+
+			// when
+			report := set.toComplianceReport(deliveryPeriod{})
+
+			// then
+			assert.Len(t, report.CountPerPostcode, 2*complianceLowestCount)
+			assert.Len(t, report.LowestCompliance, complianceLowestCount)
+			assert.Equal(t, generatedPostcode(1), report.LowestCompliance[0].Postcode)
+			assert.Equal(t, 50.0, report.WithinTimePercentage)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
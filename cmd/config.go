@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix string = "RECIPE_COUNT_"
+
+// where the effective value of an option comes from, by increasing precedence
+const (
+	sourceDefault string = "default"
+	sourceFile    string = "file"
+	sourceEnv     string = "env"
+	sourceFlag    string = "flag"
+)
+
+// envName returns the environment variable for the named flag, e.g. RECIPE_COUNT_APPROX_TOP for approx-top.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// parseFlagsWithConfig parses args into flags, and then sets the options not given as flags from the
+// RECIPE_COUNT_* environment variables or else from the -config file. Config file keys are the flag names,
+// and lists are accepted wherever a flag takes comma separated values. It returns the source of every option.
+func parseFlagsWithConfig(flags *flag.FlagSet, args []string) (map[string]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		sources[f.Name] = sourceDefault
+	})
+	flags.Visit(func(f *flag.Flag) {
+		sources[f.Name] = sourceFlag
+	})
+
+	envValues := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			envValues[f.Name] = v
+		}
+	})
+
+	configPath := flags.Lookup("config").Value.String()
+	if v, ok := envValues["config"]; ok && sources["config"] == sourceDefault {
+		configPath = v
+	}
+	fileValues := make(map[string]string)
+	if len(configPath) > 0 {
+		var err error
+		fileValues, err = loadConfigFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, layer := range []struct {
+		source string
+		values map[string]string
+	}{{sourceFile, fileValues}, {sourceEnv, envValues}} {
+		for name, v := range layer.values {
+			if flags.Lookup(name) == nil || (layer.source == sourceFile && name == "config") {
+				return nil, fmt.Errorf("unknown option %q in config file %s", name, configPath)
+			}
+			if sources[name] == sourceFlag {
+				continue
+			}
+			if err := flags.Set(name, v); err != nil {
+				return nil, fmt.Errorf("invalid %s value for option %s: %v", layer.source, name, err)
+			}
+			sources[name] = layer.source
+		}
+	}
+	return sources, nil
+}
+
+// loadConfigFile reads a YAML (or JSON, as a subset of YAML) mapping of option names to values.
+// Values are kept as written, so that e.g. postcodes with leading zeros are not read as numbers.
+func loadConfigFile(configPath string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("badly formatted config file %s: %v", configPath, err)
+	}
+	values := make(map[string]string)
+	if len(document.Content) == 0 {
+		return values, nil
+	}
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s must be a mapping of option names to values", configPath)
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		switch value.Kind {
+		case yaml.ScalarNode:
+			values[key.Value] = value.Value
+		case yaml.SequenceNode:
+			items := make([]string, 0, len(value.Content))
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("option %s in config file %s must be a list of values", key.Value, configPath)
+				}
+				items = append(items, item.Value)
+			}
+			values[key.Value] = strings.Join(items, ",")
+		default:
+			return nil, fmt.Errorf("option %s in config file %s must be a value or a list of values", key.Value, configPath)
+		}
+	}
+	return values, nil
+}
+
+// printConfig writes the effective options as a YAML config file, commenting where each value comes from.
+// The config file itself is left out, as config files can't set it.
+func printConfig(flags *flag.FlagSet, sources map[string]string, w io.Writer) error {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		tag := "!!str"
+		switch f.Value.(flag.Getter).Get().(type) {
+		case bool:
+			tag = "!!bool"
+		case int, uint, int64, uint64:
+			tag = "!!int"
+		case float64:
+			tag = "!!float"
+		}
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: f.Name},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: f.Value.String(), LineComment: "from " + sources[f.Name]},
+		)
+	})
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(mapping); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
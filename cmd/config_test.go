@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	configPath := filepath.Join(t.TempDir(), name)
+	ioutil.WriteFile(configPath, []byte(content), 0644)
+	return configPath
+}
+
+func TestParseFlagsWithConfig(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should give precedence to flags, then env, then file, then defaults": func(t *testing.T) {
+			// given
+			configPath := writeConfigFile(t, "config.yaml", "postcode: \"01234\"\ntime: 9AM-5PM\napprox-top: 20\nrecipes: [Pie, Jam]\n")
+			t.Setenv("RECIPE_COUNT_TIME", "1PM-3PM")
+			t.Setenv("RECIPE_COUNT_APPROX_TOP", "30")
+			flags, f := newCountFlagSet()
+
+			// when
+			sources, err := parseFlagsWithConfig(flags, []string{"-config", configPath, "-approx-top", "40"})
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, "01234", *f.postcode)
+			assert.Equal(t, "1PM-3PM", *f.deliveryTime)
+			assert.Equal(t, 40, *f.approxTop)
+			assert.Equal(t, "Pie,Jam", *f.recipeNames)
+			assert.Equal(t, uint(14), *f.approxPrecision)
+			assert.Equal(t, sourceFile, sources["postcode"])
+			assert.Equal(t, sourceEnv, sources["time"])
+			assert.Equal(t, sourceFlag, sources["approx-top"])
+			assert.Equal(t, sourceDefault, sources["approx-precision"])
+		},
+		"should read JSON config file from env": func(t *testing.T) {
+			// given
+			configPath := writeConfigFile(t, "config.json", `{"file": "data.json", "watch": true, "timeout": "5s"}`)
+			t.Setenv("RECIPE_COUNT_CONFIG", configPath)
+			flags, f := newCountFlagSet()
+
+			// when
+			_, err := parseFlagsWithConfig(flags, nil)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, "data.json", *f.filePath)
+			assert.True(t, *f.watch)
+			assert.Equal(t, "5s", f.timeout.String())
+		},
+		"should not parse unknown or invalid options": func(t *testing.T) {
+			// given
+			unknownPath := writeConfigFile(t, "unknown.yaml", "colour: blue\n")
+			invalidPath := writeConfigFile(t, "invalid.yaml", "approx-top: many\n")
+			nestedPath := writeConfigFile(t, "nested.yaml", "approx:\n  top: 3\n")
+			flagsUnknown, _ := newCountFlagSet()
+			flagsInvalid, _ := newCountFlagSet()
+			flagsNested, _ := newCountFlagSet()
+
+			// when
+			_, errUnknown := parseFlagsWithConfig(flagsUnknown, []string{"-config", unknownPath})
+			_, errInvalid := parseFlagsWithConfig(flagsInvalid, []string{"-config", invalidPath})
+			_, errNested := parseFlagsWithConfig(flagsNested, []string{"-config", nestedPath})
+
+			// then
+			assert.Error(t, errUnknown)
+			assert.Error(t, errInvalid)
+			assert.Error(t, errNested)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should print a config file that loads back the same options": func(t *testing.T) {
+			// given
+			configPath := writeConfigFile(t, "config.yaml", "time: 9AM-5PM\n")
+			flags, _ := newCountFlagSet()
+			sources, _ := parseFlagsWithConfig(flags, []string{"-config", configPath, "-postcode", "01234", "-approx", "-approx-epsilon", "0.5", "-queries", "10120@9AM-4PM,10208"})
+			var output bytes.Buffer
+
+			// when
+			err := printConfig(flags, sources, &output)
+
+			// then
+			printedPath := writeConfigFile(t, "printed.yaml", output.String())
+			printedFlags, f := newCountFlagSet()
+			printedSources, errParse := parseFlagsWithConfig(printedFlags, []string{"-config", printedPath})
+			assert.NoError(t, err)
+			assert.NoError(t, errParse)
+			assert.Contains(t, output.String(), "postcode: \"01234\" # from flag\n")
+			assert.Contains(t, output.String(), "approx-top: 10 # from default\n")
+			assert.NotContains(t, output.String(), "config:")
+			assert.Equal(t, "01234", *f.postcode)
+			assert.Equal(t, "9AM-5PM", *f.deliveryTime)
+			assert.True(t, *f.approx)
+			assert.Equal(t, 0.5, *f.approxEpsilon)
+			assert.Equal(t, "10120@9AM-4PM,10208", *f.queries)
+			assert.Equal(t, sourceFile, printedSources["postcode"])
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
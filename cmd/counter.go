@@ -37,40 +37,40 @@ func (s recipeCountSet) toSortedList() recipeCountList {
 	return list
 }
 
-type postcodeCountSet map[string]*postcodeMatches
+// postcodeCountSet holds its matches by value, so that counting up to 1M distinct postcodes
+// does not allocate one struct per postcode, nor leave the garbage collector that many pointers to scan.
+// Map slots holding the matches inline take more heap than a pointer per postcode would, though.
+type postcodeCountSet map[string]postcodeMatches
 type postcodeMatches struct {
 	deliveryCount           int
 	deliveryWithinTimeCount int
 }
 
 func (s postcodeCountSet) add(postcode string, isWithinTime bool) {
-	if !s.exists(postcode) {
-		s[postcode] = &postcodeMatches{}
-	}
-
-	s[postcode].deliveryCount++
+	matches := s[postcode]
+	matches.deliveryCount++
 	if isWithinTime {
-		s[postcode].deliveryWithinTimeCount++
+		matches.deliveryWithinTimeCount++
 	}
+	s[postcode] = matches
 }
 
 func (s postcodeCountSet) merge(o postcodeCountSet) {
 	for k, v := range o {
-		if !s.exists(k) {
-			s[k] = &postcodeMatches{}
-		}
-
-		s[k].deliveryCount += v.deliveryCount
-		s[k].deliveryWithinTimeCount += v.deliveryWithinTimeCount
+		matches := s[k]
+		matches.deliveryCount += v.deliveryCount
+		matches.deliveryWithinTimeCount += v.deliveryWithinTimeCount
+		s[k] = matches
 	}
 }
 
+// findBusiestPostcode returns the postcode with the most deliveries, ties broken by the lowest postcode.
 func (s postcodeCountSet) findBusiestPostcode() string {
 	maxKey := ""
 	maxVal := 0
 
 	for postcode, matches := range s {
-		if matches.deliveryCount > maxVal {
+		if matches.deliveryCount > maxVal || matches.deliveryCount == maxVal && postcode < maxKey {
 			maxKey = postcode
 			maxVal = matches.deliveryCount
 		}
@@ -80,25 +80,133 @@ func (s postcodeCountSet) findBusiestPostcode() string {
 }
 
 func (s postcodeCountSet) exists(postcode string) bool {
-	return s[postcode] != nil
+	_, exists := s[postcode]
+	return exists
 }
 
-func countRecipeDelivery(recipeDeliveryInput []recipeDelivery, options recipeCountOptions) (recipeCountSet, postcodeCountSet) {
-	recipeCountSet := make(recipeCountSet, 0)
-	postcodeCountSet := make(postcodeCountSet, 0)
+// recipeAggregator counts every recipe exactly, for the unique recipe count, count per recipe and matches by name.
+type recipeAggregator struct {
+	set          recipeCountSet
+	search       nameSearch
+	collate      string
+	order        recipeOrderOptions
+	distribution bool
+}
 
-	for _, r := range recipeDeliveryInput {
-		recipeCountSet.add(r.Recipe)
+func newRecipeAggregator(options recipeCountOptions) aggregator {
+	if options.approx.enabled {
+		return newApproxRecipeAggregator(options)
+	}
+	return &recipeAggregator{
+		set:          make(recipeCountSet),
+		search:       options.nameSearch(),
+		collate:      options.collate,
+		order:        options.recipeOrder,
+		distribution: options.named(metricDistribution),
+	}
+}
 
-		matchesSearchPostcode := r.Postcode == options.postcode
-		if !matchesSearchPostcode {
-			postcodeCountSet.add(r.Postcode, false)
-			continue
+func (a *recipeAggregator) add(r *deliveryRecord) {
+	a.set.add(r.Recipe)
+}
+
+func (a *recipeAggregator) merge(o aggregator) {
+	a.set.merge(o.(*recipeAggregator).set)
+}
+
+func (a *recipeAggregator) result(response *recipeCountResponse) {
+	sortedRecipeList := a.set.toSortedList()
+	sortedRecipeList.collate(a.collate)
+	response.UniqueRecipeCount = len(sortedRecipeList)
+	response.MatchByName = sortedRecipeList.filterByNames(a.search)
+	response.CountPerRecipe = sortedRecipeList.order(a.order)
+	if a.distribution {
+		counts := make([]int, 0, len(a.set))
+		for _, count := range a.set {
+			counts = append(counts, count)
 		}
-		deliveryPeriod, _ := parseDeliveryPeriod(r.Delivery)
-		isWithinTime := options.delivery.includes(deliveryPeriod)
-		postcodeCountSet.add(r.Postcode, isWithinTime)
+		responseDistribution(response).PerRecipe = newCountDistribution(counts)
 	}
+}
 
-	return recipeCountSet, postcodeCountSet
+// postcodeAggregator counts every postcode exactly, and the deliveries of the searched postcode within time,
+// or of every postcode for compliance. Postcode counts are also rolled up per region when regions are given.
+type postcodeAggregator struct {
+	set          postcodeCountSet
+	postcode     string
+	delivery     deliveryPeriod
+	searchStart  int
+	searchEnd    int
+	regions      *postcodeRegions
+	distribution bool
+	compliance   bool
+}
+
+func newPostcodeAggregator(options recipeCountOptions) aggregator {
+	if options.approx.enabled {
+		return newApproxPostcodeAggregator(options)
+	}
+	searchStart, searchEnd := options.delivery.minutes()
+	return &postcodeAggregator{
+		set:          make(postcodeCountSet),
+		postcode:     options.postcode,
+		delivery:     options.delivery,
+		searchStart:  searchStart,
+		searchEnd:    searchEnd,
+		regions:      options.regions,
+		distribution: options.named(metricDistribution),
+		compliance:   options.named(metricCompliance),
+	}
+}
+
+func (a *postcodeAggregator) add(r *deliveryRecord) {
+	if r.Postcode != a.postcode && !a.compliance {
+		a.set.add(r.Postcode, false)
+		return
+	}
+	deliveryWindow, ok := r.deliveryWindow()
+	a.set.add(r.Postcode, ok && deliveryWindow.includedIn(a.searchStart, a.searchEnd))
+}
+
+// merge merges into the largest of both sets, instead of copying the largest into the smallest.
+func (a *postcodeAggregator) merge(o aggregator) {
+	other := o.(*postcodeAggregator)
+	if len(other.set) > len(a.set) {
+		a.set, other.set = other.set, a.set
+	}
+	a.set.merge(other.set)
+}
+
+func (a *postcodeAggregator) result(response *recipeCountResponse) {
+	busiestPostcode := a.set.findBusiestPostcode()
+
+	// absent postcodes, e.g. on empty inputs, are reported as not found with zero counts
+	busiestMatches, busiestFound := a.set[busiestPostcode]
+	searchMatches, searchFound := a.set[a.postcode]
+
+	response.BusiestPostcode = postcodeCount{
+		Postcode:      busiestPostcode,
+		Found:         busiestFound,
+		DeliveryCount: busiestMatches.deliveryCount,
+	}
+	response.CountPerPostcodeTime = postcodeTimeCount{
+		Postcode:      a.postcode,
+		Found:         searchFound,
+		From:          a.delivery.start.Format(timestampLayout),
+		To:            a.delivery.end.Format(timestampLayout),
+		DeliveryCount: searchMatches.deliveryWithinTimeCount,
+	}
+	if a.regions != nil {
+		response.Regions = a.set.rollupRegions(a.regions)
+	}
+	if a.distribution {
+		counts := make([]int, 0, len(a.set))
+		for _, matches := range a.set {
+			counts = append(counts, matches.deliveryCount)
+		}
+		responseDistribution(response).PerPostcode = newCountDistribution(counts)
+	}
+	if a.compliance {
+		response.Compliance = a.set.toComplianceReport(a.delivery)
+	}
 }
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -101,9 +105,9 @@ func TestPostcodeCountSet(t *testing.T) {
 
 			// then
 			assert.Equal(t, 3, len(set))
-			assert.Equal(t, &postcodeMatches{deliveryCount: 3, deliveryWithinTimeCount: 2}, set["30000"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 1}, set["20000"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 1, deliveryWithinTimeCount: 0}, set["10000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 3, deliveryWithinTimeCount: 2}, set["30000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 1}, set["20000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 1, deliveryWithinTimeCount: 0}, set["10000"])
 		},
 		"should merge sets": func(t *testing.T) {
 			// given
@@ -126,11 +130,11 @@ func TestPostcodeCountSet(t *testing.T) {
 
 			// then
 			assert.Equal(t, 5, len(set))
-			assert.Equal(t, &postcodeMatches{deliveryCount: 1, deliveryWithinTimeCount: 1}, set["50000"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 1}, set["40000"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 4, deliveryWithinTimeCount: 3}, set["30000"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 1}, set["20000"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 0}, set["10000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 1, deliveryWithinTimeCount: 1}, set["50000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 1}, set["40000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 4, deliveryWithinTimeCount: 3}, set["30000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 1}, set["20000"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 0}, set["10000"])
 		},
 		"should return busiest postcode": func(t *testing.T) {
 			// given
@@ -148,6 +152,19 @@ func TestPostcodeCountSet(t *testing.T) {
 			// then
 			assert.Equal(t, "30000", busiest)
 		},
+		"should return the lowest of tied busiest postcodes": func(t *testing.T) {
+			// given
+			set := make(postcodeCountSet)
+			for i := 20; i > 0; i-- {
+				set.add(generatedPostcode(i), false)
+			}
+
+			// when
+			busiest := set.findBusiestPostcode()
+
+			// then
+			assert.Equal(t, generatedPostcode(1), busiest)
+		},
 		"should check if postcode exists": func(t *testing.T) {
 			// given
 			set := make(postcodeCountSet)
@@ -211,18 +228,39 @@ func TestCountRecipeDelivery(t *testing.T) {
 			}
 
 			// when
-			recipeCountSet, postcodeCountSet := countRecipeDelivery(recipeDeliveryInput, options)
+			aggregators := newAggregators(options)
+			err := aggregate(context.Background(), recipeDeliveryInput, options, aggregators)
+			recipeCountSet, postcodeCountSet := aggregators[0].(*recipeAggregator).set, aggregators[1].(*postcodeAggregator).set
 
 			// then
+			assert.NoError(t, err)
 			assert.Equal(t, 3, len(recipeCountSet))
 			assert.Equal(t, 3, recipeCountSet["Cherry Balsamic Pork Chops"])
 			assert.Equal(t, 1, recipeCountSet["Creamy Dill Chicken"])
 			assert.Equal(t, 2, recipeCountSet["Hot Honey Barbecue Chicken Legs"])
 
 			assert.Equal(t, 3, len(postcodeCountSet))
-			assert.Equal(t, &postcodeMatches{deliveryCount: 3, deliveryWithinTimeCount: 1}, postcodeCountSet["10120"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 1, deliveryWithinTimeCount: 0}, postcodeCountSet["10186"])
-			assert.Equal(t, &postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 0}, postcodeCountSet["10208"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 3, deliveryWithinTimeCount: 1}, postcodeCountSet["10120"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 1, deliveryWithinTimeCount: 0}, postcodeCountSet["10186"])
+			assert.Equal(t, postcodeMatches{deliveryCount: 2, deliveryWithinTimeCount: 0}, postcodeCountSet["10208"])
+		},
+		"should stop counting when context is done": func(t *testing.T) {
+			// given
+			recipeDeliveryInput := []recipeDelivery{
+				{Postcode: "10120", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Wednesday 10AM - 3PM"},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			// when
+			aggregators := newAggregators(recipeCountOptions{})
+			err := aggregate(ctx, recipeDeliveryInput, recipeCountOptions{}, aggregators)
+			recipeCountSet, postcodeCountSet := aggregators[0].(*recipeAggregator).set, aggregators[1].(*postcodeAggregator).set
+
+			// then
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.Equal(t, 0, len(recipeCountSet))
+			assert.Equal(t, 0, len(postcodeCountSet))
 		},
 	}
 
@@ -232,3 +270,145 @@ func TestCountRecipeDelivery(t *testing.T) {
 		})
 	}
 }
+
+// pointerPostcodeCountSet is the former postcodeCountSet, which allocated one struct per postcode,
+// kept as a baseline for the postcode aggregation benchmarks.
+type pointerPostcodeCountSet map[string]*postcodeMatches
+
+func (s pointerPostcodeCountSet) add(postcode string, isWithinTime bool) {
+	if s[postcode] == nil {
+		s[postcode] = &postcodeMatches{}
+	}
+
+	s[postcode].deliveryCount++
+	if isWithinTime {
+		s[postcode].deliveryWithinTimeCount++
+	}
+}
+
+func (s pointerPostcodeCountSet) merge(o pointerPostcodeCountSet) {
+	for k, v := range o {
+		if s[k] == nil {
+			s[k] = &postcodeMatches{}
+		}
+
+		s[k].deliveryCount += v.deliveryCount
+		s[k].deliveryWithinTimeCount += v.deliveryWithinTimeCount
+	}
+}
+
+const benchmarkPostcodeRecords = 10000000
+const benchmarkPostcodeDistinct = 1000000
+const benchmarkPostcodeWorkers = 8
+
+// benchmarkPostcodes generates a reproducible sequence of postcodes, where a few postcodes
+// concentrate most deliveries, as seen on the real fixtures.
+func benchmarkPostcodes() []string {
+	distinct := make([]string, benchmarkPostcodeDistinct)
+	for i := range distinct {
+		distinct[i] = fmt.Sprintf("%06d", i)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(random, 1.1, 1, benchmarkPostcodeDistinct-1)
+	postcodes := make([]string, benchmarkPostcodeRecords)
+	for i := range postcodes {
+		postcodes[i] = distinct[zipf.Uint64()]
+	}
+	return postcodes
+}
+
+// heapInuse returns the bytes of heap in use once garbage is collected.
+func heapInuse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// BenchmarkPostcodeCountSet reports, on top of the bytes allocated while counting, the heap the merged set
+// still holds once garbage is collected, as inuse-B/op. Partials are merged into the largest one, as the
+// postcode aggregator does, so that the merged set is only grown by the postcodes it lacks.
+func BenchmarkPostcodeCountSet(b *testing.B) {
+	postcodes := benchmarkPostcodes()
+	blocksize := len(postcodes) / benchmarkPostcodeWorkers
+
+	b.Run("value", func(b *testing.B) {
+		b.ReportAllocs()
+		var inuse uint64
+		for n := 0; n < b.N; n++ {
+			before := heapInuse()
+			total := make(postcodeCountSet)
+			for w := 0; w < benchmarkPostcodeWorkers; w++ {
+				partial := make(postcodeCountSet)
+				for i, p := range postcodes[w*blocksize : (w+1)*blocksize] {
+					partial.add(p, i%3 == 0)
+				}
+				if len(partial) > len(total) {
+					total, partial = partial, total
+				}
+				total.merge(partial)
+			}
+			b.StopTimer()
+			inuse += heapInuse() - before
+			runtime.KeepAlive(total)
+			b.StartTimer()
+		}
+		b.ReportMetric(float64(inuse)/float64(b.N), "inuse-B/op")
+	})
+	b.Run("pointer", func(b *testing.B) {
+		b.ReportAllocs()
+		var inuse uint64
+		for n := 0; n < b.N; n++ {
+			before := heapInuse()
+			total := make(pointerPostcodeCountSet)
+			for w := 0; w < benchmarkPostcodeWorkers; w++ {
+				partial := make(pointerPostcodeCountSet)
+				for i, p := range postcodes[w*blocksize : (w+1)*blocksize] {
+					partial.add(p, i%3 == 0)
+				}
+				if len(partial) > len(total) {
+					total, partial = partial, total
+				}
+				total.merge(partial)
+			}
+			b.StopTimer()
+			inuse += heapInuse() - before
+			runtime.KeepAlive(total)
+			b.StartTimer()
+		}
+		b.ReportMetric(float64(inuse)/float64(b.N), "inuse-B/op")
+	})
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	records := benchmarkRecipeDelivery(b)
+	options, _ := parseSearchOptions("bench.json", generatedPostcode(0), "", "")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		aggregate(context.Background(), records, options, newAggregators(options))
+	}
+}
+
+func BenchmarkMergeCountSets(b *testing.B) {
+	records := benchmarkRecipeDelivery(b)
+	options, _ := parseSearchOptions("bench.json", generatedPostcode(0), "", "")
+	blocksize := len(records) / benchmarkPostcodeWorkers
+	recipeSets := make([]recipeCountSet, benchmarkPostcodeWorkers)
+	postcodeSets := make([]postcodeCountSet, benchmarkPostcodeWorkers)
+	for w := range recipeSets {
+		aggregators := newAggregators(options)
+		aggregate(context.Background(), records[w*blocksize:(w+1)*blocksize], options, aggregators)
+		recipeSets[w], postcodeSets[w] = aggregators[0].(*recipeAggregator).set, aggregators[1].(*postcodeAggregator).set
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		recipeTotal, postcodeTotal := make(recipeCountSet), make(postcodeCountSet)
+		for w := range recipeSets {
+			recipeTotal.merge(recipeSets[w])
+			postcodeTotal.merge(postcodeSets[w])
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// the top entries the share of deliveries is reported for, as in "the top 10 recipes account for 42% of deliveries"
+var paretoTops = [...]int{1, 10, 100}
+
+// the fraction of top entries the share of deliveries is reported for, as in "the top 20% postcodes account for 80%"
+const paretoFraction float64 = 0.2
+
+type distributionReport struct {
+	PerPostcode *countDistribution `json:"deliveries_per_postcode,omitempty"`
+	PerRecipe   *countDistribution `json:"deliveries_per_recipe,omitempty"`
+}
+
+// countDistribution summarizes how deliveries are spread among postcodes or recipes. A Gini coefficient of 0
+// means every entry has as many deliveries, while it gets closer to 1 as deliveries concentrate on fewer entries.
+type countDistribution struct {
+	EntryCount       int           `json:"entry_count"`
+	DeliveryCount    int           `json:"delivery_count"`
+	Mean             float64       `json:"mean"`
+	Median           float64       `json:"median"`
+	P90              float64       `json:"p90"`
+	P95              float64       `json:"p95"`
+	P99              float64       `json:"p99"`
+	StdDev           float64       `json:"stddev"`
+	Gini             float64       `json:"gini"`
+	TopShares        []paretoShare `json:"top_shares"`
+	TopFractionShare float64       `json:"top_20_percent_share"`
+}
+
+// paretoShare is the share of deliveries, between 0 and 1, of the top entries with the most deliveries.
+type paretoShare struct {
+	Top   int     `json:"top"`
+	Share float64 `json:"share"`
+}
+
+// newCountDistribution summarizes the delivery counts of every entry, sorting counts in place.
+func newCountDistribution(counts []int) *countDistribution {
+	d := &countDistribution{EntryCount: len(counts), TopShares: make([]paretoShare, 0, len(paretoTops))}
+	if len(counts) == 0 {
+		return d
+	}
+	sort.Ints(counts)
+
+	weightedSum := 0.0
+	for i, c := range counts {
+		d.DeliveryCount += c
+		weightedSum += float64(i+1) * float64(c)
+	}
+	n := float64(len(counts))
+	total := float64(d.DeliveryCount)
+	d.Mean = total / n
+
+	variance := 0.0
+	for _, c := range counts {
+		variance += (float64(c) - d.Mean) * (float64(c) - d.Mean)
+	}
+	d.StdDev = math.Sqrt(variance / n)
+
+	d.Median = percentile(counts, 0.5)
+	d.P90 = percentile(counts, 0.9)
+	d.P95 = percentile(counts, 0.95)
+	d.P99 = percentile(counts, 0.99)
+
+	if total > 0 {
+		d.Gini = 2*weightedSum/(n*total) - (n+1)/n
+	}
+
+	for _, top := range paretoTops {
+		if top > len(counts) && len(d.TopShares) > 0 {
+			break
+		}
+		d.TopShares = append(d.TopShares, paretoShare{top, topShare(counts, top, total)})
+	}
+	d.TopFractionShare = topShare(counts, int(math.Ceil(n*paretoFraction)), total)
+	return d
+}
+
+// percentile interpolates linearly between the closest ranks of the sorted counts.
+func percentile(sorted []int, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return float64(sorted[lower]) + (rank-float64(lower))*float64(sorted[upper]-sorted[lower])
+}
+
+// topShare is the share of deliveries of the top entries of the sorted counts.
+func topShare(sorted []int, top int, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	if top > len(sorted) {
+		top = len(sorted)
+	}
+	sum := 0
+	for _, c := range sorted[len(sorted)-top:] {
+		sum += c
+	}
+	return float64(sum) / total
+}
+
+// responseDistribution returns the distribution section of the response, adding it for whichever of the
+// exact recipe and postcode aggregators reports its counts first.
+func responseDistribution(response *recipeCountResponse) *distributionReport {
+	if response.Distribution == nil {
+		response.Distribution = &distributionReport{}
+	}
+	return response.Distribution
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCountDistribution(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should summarize counts": func(t *testing.T) {
+			// when
+			d := newCountDistribution([]int{10, 3, 1, 4, 2})
+
+			// then
+			assert.Equal(t, 5, d.EntryCount)
+			assert.Equal(t, 20, d.DeliveryCount)
+			assert.Equal(t, 4.0, d.Mean)
+			assert.Equal(t, 3.0, d.Median)
+			assert.InDelta(t, 7.6, d.P90, 1e-9)
+			assert.InDelta(t, 8.8, d.P95, 1e-9)
+			assert.InDelta(t, 9.76, d.P99, 1e-9)
+			assert.InDelta(t, 3.1623, d.StdDev, 1e-4)
+			assert.InDelta(t, 0.4, d.Gini, 1e-9)
+			assert.Equal(t, []paretoShare{{Top: 1, Share: 0.5}}, d.TopShares)
+			assert.Equal(t, 0.5, d.TopFractionShare)
+		},
+		"should report shares of every top that fits": func(t *testing.T) {
+			// given
+			counts := make([]int, 200)
+			for i := range counts {
+				counts[i] = 1
+			}
+
+			// when
+			d := newCountDistribution(counts)
+
+			// then
+			assert.Equal(t, 0.0, d.Gini)
+			assert.Equal(t, 0.0, d.StdDev)
+			assert.Equal(t, []paretoShare{{Top: 1, Share: 0.005}, {Top: 10, Share: 0.05}, {Top: 100, Share: 0.5}}, d.TopShares)
+			assert.Equal(t, 0.2, d.TopFractionShare)
+		},
+		"should summarize no counts": func(t *testing.T) {
+			// when
+			d := newCountDistribution(nil)
+
+			// then
+			assert.Equal(t, &countDistribution{TopShares: []paretoShare{}}, d)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestDistributionMetric(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should add the distribution section when named": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("demo.json", "10120", "", "")
+			options.metrics, _ = parseMetricsOptions("distribution")
+			input := []recipeDelivery{
+				{Postcode: "10120", Recipe: "Creamy Dill Chicken"},
+				{Postcode: "10120", Recipe: "Speedy Steak Fajitas"},
+				{Postcode: "10208", Recipe: "Speedy Steak Fajitas"},
+			}
+			aggregators := newAggregators(options)
+
+			// when
+			err := aggregate(context.Background(), input, options, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.NoError(t, err)
+			assert.Len(t, aggregators, 2)
+			assert.Equal(t, 2, response.Distribution.PerPostcode.EntryCount)
+			assert.Equal(t, 1.5, response.Distribution.PerPostcode.Mean)
+			assert.Equal(t, 2, response.Distribution.PerRecipe.EntryCount)
+			assert.Equal(t, []paretoShare{{Top: 1, Share: 2.0 / 3}}, response.Distribution.PerRecipe.TopShares)
+		},
+		"should leave the distribution section out by default": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("demo.json", "10120", "", "")
+			aggregators := newAggregators(options)
+
+			// when
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.Nil(t, response.Distribution)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fields of a delivery that filter expressions compare
+const (
+	fieldPostcode string = "postcode"
+	fieldRecipe   string = "recipe"
+	fieldWeekday  string = "weekday"
+	fieldStart    string = "start"
+	fieldEnd      string = "end"
+)
+
+// deliveryFilter restricts which deliveries are counted, as given by a -where expression such as
+// `weekday >= Saturday or (recipe contains Chicken and postcode startswith "101")`.
+// A nil filter matches every delivery.
+type deliveryFilter struct {
+	expression string
+	match      filterFunc
+}
+
+// filterFunc matches a record, only scanning its delivery window when comparing its weekday or hours,
+// so that aggregators reuse the scanned window.
+type filterFunc func(r *deliveryRecord) bool
+
+func (f *deliveryFilter) matches(r *deliveryRecord) bool {
+	return f == nil || f.match(r)
+}
+
+// parseDeliveryFilter compiles a filter expression, made of comparisons of a field (postcode, recipe, weekday,
+// start or end) to a value with =, !=, <, <=, >, >=, contains, startswith or matches (a regular expression),
+// combined with and, or, not and parentheses. contains, startswith and matches ignore case, while the other
+// operators compare strings as they are. An empty expression returns a nil filter.
+func parseDeliveryFilter(expression string) (*deliveryFilter, error) {
+	if len(strings.TrimSpace(expression)) == 0 {
+		return nil, nil
+	}
+
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("where: unexpected %q", p.peek().text)
+	}
+	return &deliveryFilter{
+		expression: expression,
+		match:      match,
+	}, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+// operand tells whether the token can be a field or a value, as symbols and keywords can only be quoted ones.
+func (t filterToken) operand() bool {
+	if t.quoted {
+		return true
+	}
+	if strings.ContainsAny(t.text, "()=!<>&|") {
+		return false
+	}
+	for _, keyword := range []string{"and", "or", "not"} {
+		if strings.EqualFold(t.text, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				text.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("where: unterminated string starting at position %d", i)
+			}
+			tokens = append(tokens, filterToken{text.String(), true})
+			i = j + 1
+		case strings.ContainsRune("()", r):
+			tokens = append(tokens, filterToken{string(r), false})
+			i++
+		case strings.ContainsRune("=!<>&|", r):
+			j := i + 1
+			for j < len(runes) && strings.ContainsRune("=&|", runes[j]) && j-i < 2 {
+				j++
+			}
+			tokens = append(tokens, filterToken{string(runes[i:j]), false})
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()=!<>&|\"'", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, filterToken{string(runes[i:j]), false})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens   []filterToken
+	position int
+}
+
+func (p *filterParser) done() bool {
+	return p.position >= len(p.tokens)
+}
+
+func (p *filterParser) peek() filterToken {
+	if p.done() {
+		return filterToken{}
+	}
+	return p.tokens[p.position]
+}
+
+// accept consumes the next token when it's an unquoted keyword or symbol among the given ones.
+func (p *filterParser) accept(keywords ...string) bool {
+	next := p.peek()
+	if p.done() || next.quoted {
+		return false
+	}
+	for _, k := range keywords {
+		if strings.EqualFold(next.text, k) {
+			p.position++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterFunc, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *deliveryRecord) bool {
+			return l(r) || right(r)
+		}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterFunc, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *deliveryRecord) bool {
+			return l(r) && right(r)
+		}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterFunc, error) {
+	if p.accept("not", "!") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(r *deliveryRecord) bool {
+			return !inner(r)
+		}, nil
+	}
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("where: missing closing parenthesis")
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterFunc, error) {
+	if p.done() {
+		return nil, fmt.Errorf("where: unexpected end of expression")
+	}
+	if !p.peek().operand() {
+		return nil, fmt.Errorf("where: unexpected %q, expected a field", p.peek().text)
+	}
+	field := strings.ToLower(p.peek().text)
+	p.position++
+	if p.done() {
+		return nil, fmt.Errorf("where: missing operator after %q", field)
+	}
+	operator := strings.ToLower(p.peek().text)
+	p.position++
+	if p.done() {
+		return nil, fmt.Errorf("where: missing value after %q", operator)
+	}
+	if !p.peek().operand() {
+		return nil, fmt.Errorf("where: unexpected %q after %q, quote symbols and keywords compared as values", p.peek().text, operator)
+	}
+	value := p.peek().text
+	p.position++
+
+	switch field {
+	case fieldPostcode, fieldRecipe:
+		compare, err := compileStringComparison(operator, value)
+		if err != nil {
+			return nil, err
+		}
+		if field == fieldPostcode {
+			return func(r *deliveryRecord) bool { return compare(r.Postcode) }, nil
+		}
+		return func(r *deliveryRecord) bool { return compare(r.Recipe) }, nil
+	case fieldWeekday:
+		return compileWeekdayComparison(operator, value)
+	case fieldStart, fieldEnd:
+		return compileHourComparison(field, operator, value)
+	}
+	return nil, fmt.Errorf("where: unknown field %q, expected one of: postcode, recipe, weekday, start, end", field)
+}
+
+func compileStringComparison(operator string, value string) (func(string) bool, error) {
+	switch operator {
+	case "=", "==":
+		return func(s string) bool { return s == value }, nil
+	case "!=":
+		return func(s string) bool { return s != value }, nil
+	case "<":
+		return func(s string) bool { return s < value }, nil
+	case "<=":
+		return func(s string) bool { return s <= value }, nil
+	case ">":
+		return func(s string) bool { return s > value }, nil
+	case ">=":
+		return func(s string) bool { return s >= value }, nil
+	case "contains":
+		return func(s string) bool { return containsFold(s, value) }, nil
+	case "startswith":
+		return func(s string) bool { return hasPrefixFold(s, value) }, nil
+	case "matches":
+		rexp, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return nil, fmt.Errorf("where: %v", err)
+		}
+		return rexp.MatchString, nil
+	}
+	return nil, fmt.Errorf("where: unknown operator %q", operator)
+}
+
+// hasPrefixFold tells whether s starts with prefix ignoring case, comparing rune by rune
+// so that records aren't lowered into new strings.
+func hasPrefixFold(s string, prefix string) bool {
+	for _, p := range prefix {
+		r, size := utf8.DecodeRuneInString(s)
+		if size == 0 {
+			return false
+		}
+		if r != p && unicode.ToLower(r) != unicode.ToLower(p) && unicode.ToUpper(r) != unicode.ToUpper(p) {
+			return false
+		}
+		s = s[size:]
+	}
+	return true
+}
+
+// containsFold tells whether s contains substr ignoring case, without allocating.
+func containsFold(s string, substr string) bool {
+	if len(substr) == 0 {
+		return true
+	}
+	for i := range s {
+		if hasPrefixFold(s[i:], substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileNumberComparison(operator string, value int) (func(int) bool, error) {
+	switch operator {
+	case "=", "==":
+		return func(n int) bool { return n == value }, nil
+	case "!=":
+		return func(n int) bool { return n != value }, nil
+	case "<":
+		return func(n int) bool { return n < value }, nil
+	case "<=":
+		return func(n int) bool { return n <= value }, nil
+	case ">":
+		return func(n int) bool { return n > value }, nil
+	case ">=":
+		return func(n int) bool { return n >= value }, nil
+	}
+	return nil, fmt.Errorf("where: operator %q can't compare weekdays or hours", operator)
+}
+
+// compileWeekdayComparison orders weekdays from Monday to Sunday, e.g. `weekday >= Saturday` for weekends.
+func compileWeekdayComparison(operator string, value string) (filterFunc, error) {
+	weekday := -1
+	for d, name := range weekdays {
+		if strings.EqualFold(name, value) {
+			weekday = d
+		}
+	}
+	if weekday < 0 {
+		return nil, fmt.Errorf("where: unknown weekday %q", value)
+	}
+	compare, err := compileNumberComparison(operator, weekday)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *deliveryRecord) bool {
+		w, ok := r.deliveryWindow()
+		return ok && compare(w.weekday())
+	}, nil
+}
+
+// compileHourComparison compares the delivery start or end to an hour such as 10AM.
+func compileHourComparison(field string, operator string, value string) (filterFunc, error) {
+	hour, i, ok := scanHour(strings.ToUpper(value), 0)
+	if !ok || i != len(value) {
+		return nil, fmt.Errorf("where: badly formatted hour %q", value)
+	}
+	compare, err := compileNumberComparison(operator, hour)
+	if err != nil {
+		return nil, err
+	}
+	if field == fieldStart {
+		return func(r *deliveryRecord) bool {
+			w, ok := r.deliveryWindow()
+			return ok && compare(w.start%minutesPerDay)
+		}, nil
+	}
+	return func(r *deliveryRecord) bool {
+		w, ok := r.deliveryWindow()
+		return ok && compare(w.end%minutesPerDay)
+	}, nil
+}
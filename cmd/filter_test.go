@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeliveryFilter(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10186", Recipe: "Hot Honey Barbecue Chicken Legs", Delivery: "Saturday 1AM - 8PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Sunday 11AM - 2PM"},
+		{Postcode: "10101", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Someday"},
+	}
+	matching := func(filter *deliveryFilter) []string {
+		postcodes := make([]string, 0)
+		for _, r := range input {
+			if filter.matches(&deliveryRecord{recipeDelivery: r}) {
+				postcodes = append(postcodes, r.Postcode)
+			}
+		}
+		return postcodes
+	}
+
+	tests := map[string]func(*testing.T){
+		"should match every delivery without expression": func(t *testing.T) {
+			// when
+			filter, err := parseDeliveryFilter("  ")
+
+			// then
+			assert.NoError(t, err)
+			assert.Nil(t, filter)
+			assert.Equal(t, []string{"10120", "10186", "10208", "10101"}, matching(filter))
+		},
+		"should filter weekend deliveries": func(t *testing.T) {
+			// when
+			filter, err := parseDeliveryFilter("weekday >= saturday")
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"10186", "10208"}, matching(filter))
+		},
+		"should filter recipes containing a word in postcodes starting with a prefix": func(t *testing.T) {
+			// when
+			filter, err := parseDeliveryFilter(`recipe contains chicken and postcode startswith "101"`)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"10120", "10186"}, matching(filter))
+		},
+		"should ignore case in string operators but not in comparisons": func(t *testing.T) {
+			// when
+			matched, errMatched := parseDeliveryFilter("recipe matches '^hot|dill'")
+			compared, errCompared := parseDeliveryFilter("recipe = 'speedy steak fajitas'")
+
+			// then
+			assert.NoError(t, errMatched)
+			assert.NoError(t, errCompared)
+			assert.Equal(t, []string{"10120", "10186"}, matching(matched))
+			assert.Equal(t, []string{}, matching(compared))
+		},
+		"should combine operators with precedence and parentheses": func(t *testing.T) {
+			// when
+			withoutParentheses, errWithout := parseDeliveryFilter("postcode = 10101 or postcode = 10208 and start >= 11AM")
+			withParentheses, errWith := parseDeliveryFilter("(postcode = 10101 || postcode = 10208) && start >= 11AM")
+			negated, errNegated := parseDeliveryFilter("not (end < 3PM) and !(recipe matches '^Hot')")
+
+			// then
+			assert.NoError(t, errWithout)
+			assert.NoError(t, errWith)
+			assert.NoError(t, errNegated)
+			assert.Equal(t, []string{"10208", "10101"}, matching(withoutParentheses))
+			assert.Equal(t, []string{"10208"}, matching(withParentheses))
+			assert.Equal(t, []string{"10120", "10101"}, matching(negated))
+		},
+		"should compare quoted symbols and keywords as values": func(t *testing.T) {
+			// when
+			symbol, errSymbol := parseDeliveryFilter("recipe contains ')'")
+			keyword, errKeyword := parseDeliveryFilter(`recipe contains "and"`)
+
+			// then
+			assert.NoError(t, errSymbol)
+			assert.NoError(t, errKeyword)
+			assert.Equal(t, []string{}, matching(symbol))
+			assert.Equal(t, []string{}, matching(keyword))
+		},
+		"should ignore case in string operators without allocating": func(t *testing.T) {
+			// given
+			filter, _ := parseDeliveryFilter("recipe contains CHICKEN or recipe startswith 'speedy'")
+			r := &deliveryRecord{recipeDelivery: input[2]}
+
+			// when
+			allocs := testing.AllocsPerRun(100, func() {
+				filter.matches(r)
+			})
+
+			// then
+			assert.True(t, filter.matches(r))
+			assert.Zero(t, allocs)
+		},
+		"should not match time comparisons for bad deliveries": func(t *testing.T) {
+			// when
+			filter, _ := parseDeliveryFilter("weekday != Monday")
+
+			// then
+			assert.Equal(t, []string{"10120", "10186", "10208"}, matching(filter))
+		},
+		"should not parse invalid expressions": func(t *testing.T) {
+			expressions := []string{
+				"colour = red",
+				"postcode",
+				"postcode =",
+				"postcode ~ 10120",
+				"recipe matches '('",
+				"weekday = Someday",
+				"weekday contains Sun",
+				"start > 25PM",
+				"(postcode = 10120",
+				"postcode = 10120 postcode",
+				"recipe = 'Chicken",
+				"postcode = 10120 and",
+				"recipe = )",
+				"recipe contains (",
+				"postcode = and",
+				"= postcode 10120",
+				"not = 10120",
+			}
+			for _, expression := range expressions {
+				// when
+				_, err := parseDeliveryFilter(expression)
+
+				// then
+				assert.Error(t, err, expression)
+			}
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestFilterBeforeCounting(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 11AM - 2PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 11AM - 2PM"},
+	}
+	options, _ := parseSearchOptions("demo.json", "10120", "10AM-3PM", "Steak")
+	options.where, _ = parseDeliveryFilter("weekday = Saturday")
+
+	// when
+	aggregators := newAggregators(options)
+	err := aggregate(context.Background(), input, options, aggregators)
+	recipeSet, postcodeSet := aggregators[0].(*recipeAggregator).set, aggregators[1].(*postcodeAggregator).set
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, recipeCountSet{"Speedy Steak Fajitas": 2}, recipeSet)
+	assert.Equal(t, 1, postcodeSet["10120"].deliveryCount)
+	assert.Equal(t, 1, postcodeSet["10208"].deliveryCount)
+}
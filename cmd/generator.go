@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var recipeAdjectives = [...]string{"Speedy", "Creamy", "Cheesy", "Crispy", "Spicy", "Hearty", "Zesty", "Smoky", "Tangy", "Sweet"}
+var recipeMains = [...]string{"Chicken", "Steak", "Pork", "Salmon", "Tilapia", "Shrimp", "Tofu", "Mushroom", "Veggie", "Potato"}
+var recipeDishes = [...]string{"Fajitas", "Tacos", "Curry", "Risotto", "Pasta", "Burgers", "Stir-Fry", "Bowls", "Skillet", "Flatbreads"}
+
+// the latest start hour for a delivery, as deliveries must end by 11PM
+const generatorLatestStartHour int = 22
+
+type generatorOptions struct {
+	seed         int64
+	records      int
+	recipes      int
+	postcodes    int
+	skew         float64
+	weekdays     []float64
+	hours        []float64
+	invalidRatio float64
+	format       string
+}
+
+func parseWeights(weights string, size int) ([]float64, error) {
+	list := make([]float64, size)
+	if len(weights) == 0 {
+		for i := range list {
+			list[i] = 1
+		}
+		return list, nil
+	}
+
+	values := strings.Split(weights, ",")
+	if len(values) > size {
+		return nil, fmt.Errorf("expected at most %d weights, got %d", size, len(values))
+	}
+	for i, v := range values {
+		w, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("badly formatted weight %q", v)
+		}
+		list[i] = w
+	}
+	return list, nil
+}
+
+func parseGeneratorOptions(seed int64, records int, recipes int, postcodes int, skew float64, weekdayWeights string, hourWeights string, invalidRatio float64, format string) (generatorOptions, error) {
+	if records < 0 {
+		return generatorOptions{}, errors.New("records must not be negative")
+	}
+	if recipes < 1 || postcodes < 1 {
+		return generatorOptions{}, errors.New("recipes and postcodes must be positive numbers")
+	}
+	if skew != 0 && skew <= 1 {
+		return generatorOptions{}, errors.New("skew must be 0 (uniform) or greater than 1")
+	}
+	if invalidRatio < 0 || invalidRatio > 1 {
+		return generatorOptions{}, errors.New("invalid ratio must be between 0 and 1")
+	}
+	if format != "json" {
+		return generatorOptions{}, fmt.Errorf("unsupported format %q", format)
+	}
+	weekdayList, err := parseWeights(weekdayWeights, len(weekdays))
+	if err != nil {
+		return generatorOptions{}, err
+	}
+	hourList, err := parseWeights(hourWeights, generatorLatestStartHour+1)
+	if err != nil {
+		return generatorOptions{}, err
+	}
+
+	return generatorOptions{
+		seed:         seed,
+		records:      records,
+		recipes:      recipes,
+		postcodes:    postcodes,
+		skew:         skew,
+		weekdays:     weekdayList,
+		hours:        hourList,
+		invalidRatio: invalidRatio,
+		format:       format,
+	}, nil
+}
+
+// weightedChoice picks indexes with a probability proportional to their weights.
+type weightedChoice struct {
+	cumulative []float64
+}
+
+func newWeightedChoice(weights []float64) (weightedChoice, error) {
+	cumulative := make([]float64, len(weights))
+	total := 0.0
+	for i, w := range weights {
+		total += w
+		cumulative[i] = total
+	}
+	if total == 0 {
+		return weightedChoice{}, errors.New("at least one weight must be positive")
+	}
+	return weightedChoice{cumulative}, nil
+}
+
+func (c weightedChoice) pick(random *rand.Rand) int {
+	target := random.Float64() * c.cumulative[len(c.cumulative)-1]
+	for i, v := range c.cumulative {
+		if target < v {
+			return i
+		}
+	}
+	return len(c.cumulative) - 1
+}
+
+// skewedChoice picks indexes in [0, size) uniformly, or following a Zipf distribution when skew is set.
+type skewedChoice struct {
+	size int
+	zipf *rand.Zipf
+}
+
+func newSkewedChoice(random *rand.Rand, size int, skew float64) skewedChoice {
+	if skew == 0 || size == 1 {
+		return skewedChoice{size: size}
+	}
+	return skewedChoice{size: size, zipf: rand.NewZipf(random, skew, 1, uint64(size-1))}
+}
+
+func (c skewedChoice) pick(random *rand.Rand) int {
+	if c.zipf == nil {
+		return random.Intn(c.size)
+	}
+	return int(c.zipf.Uint64())
+}
+
+func generatedRecipeName(i int) string {
+	a, m, d := len(recipeAdjectives), len(recipeMains), len(recipeDishes)
+	name := fmt.Sprintf("%s %s %s", recipeAdjectives[i%a], recipeMains[(i/a)%m], recipeDishes[(i/(a*m))%d])
+	if round := i / (a * m * d); round > 0 {
+		name = fmt.Sprintf("%s (v%d)", name, round+1)
+	}
+	return name
+}
+
+func generatedPostcode(i int) string {
+	return strconv.Itoa(10000 + i)
+}
+
+func formatHour(hour int) string {
+	return time.Date(0, 1, 1, hour, 0, 0, 0, time.UTC).Format(timestampLayout)
+}
+
+// generateFixtures writes reproducible fixtures: the same options always produce the same records.
+func generateFixtures(options generatorOptions, w io.Writer) error {
+	random := rand.New(rand.NewSource(options.seed))
+	weekdayChoice, err := newWeightedChoice(options.weekdays)
+	if err != nil {
+		return err
+	}
+	hourChoice, err := newWeightedChoice(options.hours)
+	if err != nil {
+		return err
+	}
+	recipeChoice := newSkewedChoice(random, options.recipes, options.skew)
+	postcodeChoice := newSkewedChoice(random, options.postcodes, options.skew)
+
+	buffer := bufio.NewWriter(w)
+	buffer.WriteString("[")
+	for i := 0; i < options.records; i++ {
+		start := hourChoice.pick(random)
+		end := start + 1 + random.Intn(generatorLatestStartHour+1-start)
+		record := recipeDelivery{
+			Postcode: generatedPostcode(postcodeChoice.pick(random)),
+			Recipe:   generatedRecipeName(recipeChoice.pick(random)),
+			Delivery: fmt.Sprintf("%s %s - %s", weekdays[weekdayChoice.pick(random)], formatHour(start), formatHour(end)),
+		}
+		if random.Float64() < options.invalidRatio {
+			record = invalidateRecord(record, random)
+		}
+
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString("\n  ")
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buffer.Write(line)
+	}
+	buffer.WriteString("\n]\n")
+	return buffer.Flush()
+}
+
+func invalidateRecord(record recipeDelivery, random *rand.Rand) recipeDelivery {
+	switch random.Intn(3) {
+	case 0:
+		record.Postcode = ""
+	case 1:
+		record.Postcode = "12345678901"
+	default:
+		record.Delivery = "Someday 13XM - 25YM"
+	}
+	return record
+}
+
+func runGenerate(args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	setUsage(flags, "generate [options]", "Writes reproducible synthetic fixtures: the same options always generate the same records.")
+	outPath := flags.String("out", "", "output file path (defaults to stdout)")
+	seed := flags.Int64("seed", 1, "random seed, the same seed always generates the same fixtures")
+	records := flags.Int("records", 1000, "number of records")
+	recipes := flags.Int("recipes", 100, "number of distinct recipes")
+	postcodes := flags.Int("postcodes", 1000, "number of distinct postcodes")
+	skew := flags.Float64("skew", 0, "Zipf exponent for recipe and postcode popularity, greater than 1 (0 is uniform)")
+	weekdayWeights := flags.String("weekdays", "", "relative weights of each weekday from Monday, separated by commas (uniform by default)")
+	hourWeights := flags.String("hours", "", "relative weights of each delivery start hour from 12AM to 10PM, separated by commas (uniform by default)")
+	invalidRatio := flags.Float64("invalid", 0, "ratio of invalid records, between 0 and 1")
+	format := flags.String("format", "json", "output format, one of: json")
+	if err := flags.Parse(args); err != nil {
+		return usageError(err)
+	}
+
+	options, err := parseGeneratorOptions(*seed, *records, *recipes, *postcodes, *skew, *weekdayWeights, *hourWeights, *invalidRatio, *format)
+	if err != nil {
+		return usageError(err)
+	}
+	if err := generateFixturesFile(options, *outPath); err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOK
+}
+
+func generateFixturesFile(options generatorOptions, outPath string) error {
+	if len(outPath) == 0 {
+		return generateFixtures(options, os.Stdout)
+	}
+	file, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if err := generateFixtures(options, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
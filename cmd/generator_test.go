@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateFixtures(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should generate the same fixtures for the same seed": func(t *testing.T) {
+			// given
+			options, _ := parseGeneratorOptions(42, 100, 10, 20, 1.3, "", "", 0.1, "json")
+			var first, second bytes.Buffer
+
+			// when
+			generateFixtures(options, &first)
+			generateFixtures(options, &second)
+
+			// then
+			assert.Equal(t, first.String(), second.String())
+		},
+		"should generate the requested cardinalities": func(t *testing.T) {
+			// given
+			options, _ := parseGeneratorOptions(1, 2000, 3, 5, 0, "", "", 0, "json")
+			var output bytes.Buffer
+
+			// when
+			err := generateFixtures(options, &output)
+
+			// then
+			var records []recipeDelivery
+			json.Unmarshal(output.Bytes(), &records)
+			recipes, postcodes := make(recipeCountSet), make(postcodeCountSet)
+			for _, r := range records {
+				recipes.add(r.Recipe)
+				postcodes.add(r.Postcode, false)
+				_, errDelivery := parseDeliveryPeriod(r.Delivery)
+				assert.NoError(t, errDelivery)
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 2000, len(records))
+			assert.Equal(t, 3, len(recipes))
+			assert.Equal(t, 5, len(postcodes))
+		},
+		"should follow weekday and hour weights": func(t *testing.T) {
+			// given
+			options, _ := parseGeneratorOptions(1, 50, 3, 5, 0, "0,0,0,0,0,1", "0,0,0,0,0,0,0,0,0,1", 0, "json")
+			var output bytes.Buffer
+
+			// when
+			generateFixtures(options, &output)
+
+			// then
+			var records []recipeDelivery
+			json.Unmarshal(output.Bytes(), &records)
+			for _, r := range records {
+				assert.Regexp(t, `^Saturday 9AM - `, r.Delivery)
+			}
+		},
+		"should generate invalid records": func(t *testing.T) {
+			// given
+			options, _ := parseGeneratorOptions(1, 50, 3, 5, 0, "", "", 1, "json")
+			var output bytes.Buffer
+
+			// when
+			generateFixtures(options, &output)
+
+			// then
+			var records []recipeDelivery
+			json.Unmarshal(output.Bytes(), &records)
+			for _, r := range records {
+				_, errDelivery := parseDeliveryPeriod(r.Delivery)
+				assert.True(t, len(r.Postcode) == 0 || len(r.Postcode) > 10 || errDelivery != nil)
+			}
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestParseGeneratorOptions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should parse generator options": func(t *testing.T) {
+			// when
+			options, err := parseGeneratorOptions(7, 10, 2, 3, 1.5, "1,2", "", 0.5, "json")
+
+			// then
+			assert.Equal(t, []float64{1, 2, 0, 0, 0, 0, 0}, options.weekdays)
+			assert.Equal(t, generatorLatestStartHour+1, len(options.hours))
+			assert.NoError(t, err)
+		},
+		"should not parse invalid generator options": func(t *testing.T) {
+			// when
+			_, errSkew := parseGeneratorOptions(7, 10, 2, 3, 0.5, "", "", 0, "json")
+			_, errRatio := parseGeneratorOptions(7, 10, 2, 3, 0, "", "", 2, "json")
+			_, errWeights := parseGeneratorOptions(7, 10, 2, 3, 0, "1,2,3,4,5,6,7,8", "", 0, "json")
+			_, errFormat := parseGeneratorOptions(7, 10, 2, 3, 0, "", "", 0, "xml")
+
+			// then
+			assert.Error(t, errSkew)
+			assert.Error(t, errRatio)
+			assert.Error(t, errWeights)
+			assert.Error(t, errFormat)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+const benchmarkFixtureRecords = 1000000
+
+// benchmark fixtures are generated and decoded once, as every benchmark is run several times to find its b.N
+var (
+	benchmarkFixturesOnce  sync.Once
+	benchmarkFixturesBytes []byte
+	benchmarkRecords       []recipeDelivery
+	benchmarkFixturesErr   error
+)
+
+// benchmarkFixtures generates fixtures shaped like the real ones: skewed popularity and 2K recipes.
+// Callers share the returned fixtures, and must not modify them.
+func benchmarkFixtures(b *testing.B) []byte {
+	benchmarkFixturesOnce.Do(func() {
+		options, _ := parseGeneratorOptions(1, benchmarkFixtureRecords, 2000, 100000, 1.1, "", "", 0, "json")
+		var output bytes.Buffer
+		if benchmarkFixturesErr = generateFixtures(options, &output); benchmarkFixturesErr != nil {
+			return
+		}
+		benchmarkFixturesBytes = output.Bytes()
+		benchmarkFixturesErr = json.Unmarshal(benchmarkFixturesBytes, &benchmarkRecords)
+	})
+	if benchmarkFixturesErr != nil {
+		b.Fatal(benchmarkFixturesErr)
+	}
+	return benchmarkFixturesBytes
+}
+
+func benchmarkRecipeDelivery(b *testing.B) []recipeDelivery {
+	benchmarkFixtures(b)
+	return benchmarkRecords
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dimensions deliveries can be grouped by
+const (
+	groupByRecipe   string = "recipe"
+	groupByPostcode string = "postcode"
+	groupByWeekday  string = "weekday"
+	groupByHour     string = "hour"
+	groupByWindow   string = "window"
+)
+
+var groupByDimensions = [...]string{groupByRecipe, groupByPostcode, groupByWeekday, groupByHour, groupByWindow}
+
+// orders groups can be sorted by
+const (
+	groupSortCount    string = "count"
+	groupSortCountAsc string = "count-asc"
+	groupSortGroup    string = "group"
+)
+
+type groupByOptions struct {
+	dimensions []string
+	sort       string
+	limit      int
+}
+
+func (o groupByOptions) enabled() bool {
+	return len(o.dimensions) > 0
+}
+
+// needsWindow reports whether grouping needs the parsed delivery window of each record.
+func (o groupByOptions) needsWindow() bool {
+	for _, d := range o.dimensions {
+		if d == groupByWeekday || d == groupByHour || d == groupByWindow {
+			return true
+		}
+	}
+	return false
+}
+
+// byPostcodeOrRecipe reports whether groups are keyed by postcode or recipe, of which there are as many groups.
+func (o groupByOptions) byPostcodeOrRecipe() bool {
+	for _, d := range o.dimensions {
+		if d == groupByPostcode || d == groupByRecipe {
+			return true
+		}
+	}
+	return false
+}
+
+func parseGroupByOptions(dimensions string, sortBy string, limit int) (groupByOptions, error) {
+	options := groupByOptions{sort: sortBy, limit: limit}
+	if sortBy != groupSortCount && sortBy != groupSortCountAsc && sortBy != groupSortGroup {
+		return groupByOptions{}, fmt.Errorf("unknown group sort %q, expected one of: count, count-asc, group", sortBy)
+	}
+	if limit < 0 {
+		return groupByOptions{}, fmt.Errorf("group limit must not be negative")
+	}
+	if len(dimensions) == 0 {
+		return options, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, d := range strings.Split(dimensions, ",") {
+		d = strings.TrimSpace(d)
+		known := false
+		for _, k := range groupByDimensions {
+			known = known || d == k
+		}
+		if !known {
+			return groupByOptions{}, fmt.Errorf("unknown group-by dimension %q, expected any of: %s", d, strings.Join(groupByDimensions[:], ","))
+		}
+		if seen[d] {
+			return groupByOptions{}, fmt.Errorf("group-by dimension %q is repeated", d)
+		}
+		seen[d] = true
+		options.dimensions = append(options.dimensions, d)
+	}
+	return options, nil
+}
+
+// groupKey holds the value of every dimension a delivery is grouped by, leaving the others zeroed,
+// so that grouping does not allocate a key per record.
+type groupKey struct {
+	recipe   string
+	postcode string
+	weekday  int
+	hour     int
+	window   deliveryWindow
+}
+
+func newGroupKey(r recipeDelivery, w deliveryWindow, options groupByOptions) groupKey {
+	key := groupKey{}
+	for _, d := range options.dimensions {
+		switch d {
+		case groupByRecipe:
+			key.recipe = r.Recipe
+		case groupByPostcode:
+			key.postcode = r.Postcode
+		case groupByWeekday:
+			key.weekday = w.weekday()
+		case groupByHour:
+			key.hour = w.start % minutesPerDay / 60
+		case groupByWindow:
+			key.window = deliveryWindow{w.start % minutesPerDay, w.end % minutesPerDay}
+		}
+	}
+	return key
+}
+
+// value returns the readable value of the given dimension, as found in the response.
+func (k groupKey) value(dimension string) string {
+	switch dimension {
+	case groupByRecipe:
+		return k.recipe
+	case groupByPostcode:
+		return k.postcode
+	case groupByWeekday:
+		return weekdays[k.weekday]
+	case groupByHour:
+		return formatHour(k.hour)
+	default:
+		return formatHour(k.window.start/60) + " - " + formatHour(k.window.end/60)
+	}
+}
+
+// less orders keys by their dimensions, in the order they were given.
+func (k groupKey) less(o groupKey, dimensions []string) bool {
+	for _, d := range dimensions {
+		switch {
+		case d == groupByRecipe && k.recipe != o.recipe:
+			return k.recipe < o.recipe
+		case d == groupByPostcode && k.postcode != o.postcode:
+			return k.postcode < o.postcode
+		case d == groupByWeekday && k.weekday != o.weekday:
+			return k.weekday < o.weekday
+		case d == groupByHour && k.hour != o.hour:
+			return k.hour < o.hour
+		case d == groupByWindow && k.window != o.window:
+			if k.window.start != o.window.start {
+				return k.window.start < o.window.start
+			}
+			return k.window.end < o.window.end
+		}
+	}
+	return false
+}
+
+type groupCountSet map[groupKey]int
+
+func (s groupCountSet) add(key groupKey) {
+	s[key]++
+}
+
+func (s groupCountSet) merge(o groupCountSet) {
+	for k, v := range o {
+		s[k] += v
+	}
+}
+
+// groupAggregator counts deliveries per group. Records with badly formatted deliveries are left out of
+// groups by weekday, hour or window.
+type groupAggregator struct {
+	set         groupCountSet
+	options     groupByOptions
+	needsWindow bool
+}
+
+func newGroupAggregator(options recipeCountOptions) aggregator {
+	return &groupAggregator{make(groupCountSet), options.groupBy, options.groupBy.needsWindow()}
+}
+
+func (a *groupAggregator) add(r *deliveryRecord) {
+	var window deliveryWindow
+	if a.needsWindow {
+		var ok bool
+		if window, ok = r.deliveryWindow(); !ok {
+			return
+		}
+	}
+	a.set.add(newGroupKey(r.recipeDelivery, window, a.options))
+}
+
+func (a *groupAggregator) merge(o aggregator) {
+	a.set.merge(o.(*groupAggregator).set)
+}
+
+func (a *groupAggregator) result(response *recipeCountResponse) {
+	response.CountPerGroup = a.set.toReport(a.options)
+}
+
+type groupCountReport struct {
+	GroupBy    []string     `json:"group_by"`
+	GroupCount int          `json:"group_count"`
+	Groups     []groupCount `json:"groups"`
+}
+
+type groupCount struct {
+	Group         map[string]string `json:"group"`
+	DeliveryCount int               `json:"count"`
+}
+
+// toReport sorts the groups, breaking ties in counts by group, and keeps up to options.limit of them.
+func (s groupCountSet) toReport(options groupByOptions) *groupCountReport {
+	keys := make([]groupKey, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := s[keys[i]], s[keys[j]]
+		switch {
+		case options.sort == groupSortCount && ci != cj:
+			return ci > cj
+		case options.sort == groupSortCountAsc && ci != cj:
+			return ci < cj
+		}
+		return keys[i].less(keys[j], options.dimensions)
+	})
+	if options.limit > 0 && len(keys) > options.limit {
+		keys = keys[:options.limit]
+	}
+
+	groups := make([]groupCount, 0, len(keys))
+	for _, k := range keys {
+		group := make(map[string]string, len(options.dimensions))
+		for _, d := range options.dimensions {
+			group[d] = k.value(d)
+		}
+		groups = append(groups, groupCount{group, s[k]})
+	}
+	return &groupCountReport{
+		GroupBy:    options.dimensions,
+		GroupCount: len(s),
+		Groups:     groups,
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGroupByOptions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should parse group-by options": func(t *testing.T) {
+			// when
+			options, err := parseGroupByOptions("postcode, weekday", groupSortGroup, 5)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, groupByOptions{dimensions: []string{"postcode", "weekday"}, sort: "group", limit: 5}, options)
+			assert.True(t, options.enabled())
+			assert.True(t, options.needsWindow())
+		},
+		"should not parse invalid group-by options": func(t *testing.T) {
+			// when
+			_, errDimension := parseGroupByOptions("postcode,colour", groupSortCount, 0)
+			_, errRepeated := parseGroupByOptions("postcode,postcode", groupSortCount, 0)
+			_, errSort := parseGroupByOptions("postcode", "random", 0)
+			_, errLimit := parseGroupByOptions("postcode", groupSortCount, -1)
+
+			// then
+			assert.Error(t, errDimension)
+			assert.Error(t, errRepeated)
+			assert.Error(t, errSort)
+			assert.Error(t, errLimit)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+// countPerGroup counts the groups of input in a single worker.
+func countPerGroup(input []recipeDelivery, groupBy groupByOptions) *groupCountReport {
+	options := recipeCountOptions{groupBy: groupBy}
+	aggregators := newAggregators(options)
+	aggregate(context.Background(), input, options, aggregators)
+	return buildAggregatedResponse(aggregators).CountPerGroup
+}
+
+func TestCountGroups(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10208", Recipe: "Creamy Dill Chicken", Delivery: "Thursday 11AM - 2PM"},
+		{Postcode: "10120", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10186", Recipe: "Cherry Balsamic Pork Chops", Delivery: "Saturday 1AM - 8PM"},
+		{Postcode: "10120", Recipe: "Hot Honey Barbecue Chicken Legs", Delivery: "Wednesday 10AM - 4PM"},
+		{Postcode: "10208", Recipe: "Hot Honey Barbecue Chicken Legs", Delivery: "Someday"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should count and merge groups sorted by count": func(t *testing.T) {
+			// given
+			options := recipeCountOptions{}
+			options.groupBy, _ = parseGroupByOptions("postcode,weekday", groupSortCount, 0)
+
+			// when
+			response := aggregateInTwoWorkers(t, options, input, 3)
+
+			// then
+			assert.Equal(t, &groupCountReport{
+				GroupBy:    []string{"postcode", "weekday"},
+				GroupCount: 3,
+				Groups: []groupCount{
+					{Group: map[string]string{"postcode": "10120", "weekday": "Wednesday"}, DeliveryCount: 3},
+					{Group: map[string]string{"postcode": "10186", "weekday": "Saturday"}, DeliveryCount: 1},
+					{Group: map[string]string{"postcode": "10208", "weekday": "Thursday"}, DeliveryCount: 1},
+				},
+			}, response.CountPerGroup)
+		},
+		"should keep records with bad deliveries out of time groups only": func(t *testing.T) {
+			// given
+			byRecipe, _ := parseGroupByOptions("recipe", groupSortCount, 0)
+			byWindow, _ := parseGroupByOptions("window", groupSortCount, 0)
+
+			// when
+			recipeReport := countPerGroup(input, byRecipe)
+			windowReport := countPerGroup(input, byWindow)
+
+			// then
+			assert.Equal(t, groupCount{Group: map[string]string{"recipe": "Hot Honey Barbecue Chicken Legs"}, DeliveryCount: 2}, recipeReport.Groups[1])
+			total := 0
+			for _, g := range windowReport.Groups {
+				total += g.DeliveryCount
+			}
+			assert.Equal(t, 5, total)
+		},
+		"should sort by group and apply limit": func(t *testing.T) {
+			// given
+			options, _ := parseGroupByOptions("hour,window", groupSortGroup, 2)
+
+			// when
+			report := countPerGroup(input, options)
+
+			// then
+			assert.Equal(t, 4, report.GroupCount)
+			assert.Equal(t, []groupCount{
+				{Group: map[string]string{"hour": "1AM", "window": "1AM - 8PM"}, DeliveryCount: 1},
+				{Group: map[string]string{"hour": "10AM", "window": "10AM - 3PM"}, DeliveryCount: 2},
+			}, report.Groups)
+		},
+		"should sort by ascending count": func(t *testing.T) {
+			// given
+			options, _ := parseGroupByOptions("recipe", groupSortCountAsc, 1)
+
+			// when
+			report := countPerGroup(input, options)
+
+			// then
+			assert.Equal(t, []groupCount{
+				{Group: map[string]string{"recipe": "Creamy Dill Chicken"}, DeliveryCount: 1},
+			}, report.Groups)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
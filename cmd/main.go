@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"io/ioutil"
 	"log"
 	"os"
-	"runtime"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
 const postcodeDefault string = "10120"
@@ -14,60 +20,314 @@ const deliveryTimeDefault string = "10AM-3PM"
 const recipeNamesDefault string = "Potato,Veggie,Mushroom"
 const timestampLayout string = "3PM"
 
+// exit codes, where exitUsage matches the one used by the flag package
+const (
+	exitOK         int = 0
+	exitError      int = 1
+	exitUsage      int = 2
+	exitNoData     int = 3
+	exitIncomplete int = 4
+	exitInvalid    int = 5
+)
+
 func main() {
-	// parses input option flags
-	filePath := flag.String("file", "", "fixtures data file path (required)")
-	postcode := flag.String("postcode", postcodeDefault, "postcode to search for")
-	deliveryTime := flag.String("time", deliveryTimeDefault, "delivery time to search for")
-	recipeNames := flag.String("recipes", recipeNamesDefault, "recipe(s) name(s) to search for, separated by commas")
-	flag.Parse()
-	options, err := parseCountOptions(*filePath, *postcode, *deliveryTime, *recipeNames)
+	os.Exit(run(os.Args[1:]))
+}
+
+// runConfig handles the config subcommands, of which there is only `config print` for now.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		log.Println("usage: recipe-count config print [count options]")
+		return exitUsage
+	}
+
+	flags, _ := newCountFlagSet()
+	setUsage(flags, "config print [count options]", "Writes the effective count configuration, commenting where each value comes from.")
+	sources, err := parseFlagsWithConfig(flags, args[1:])
 	if err != nil {
-		log.Fatal(err)
+		return usageError(err)
 	}
+	if err := printConfig(flags, sources, os.Stdout); err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOK
+}
 
-	// reads input file content
-	fileContent, err := ioutil.ReadFile(options.filePath)
+// runCount counts the deliveries in the fixtures data file and writes the JSON response.
+// A report is still written when there is no data for the searched postcode, but exits with exitNoData.
+func runCount(args []string) int {
+	// parses input option flags, completed by the environment and config file
+	flags, f := newCountFlagSet()
+	if _, err := parseFlagsWithConfig(flags, args); err != nil {
+		return usageError(err)
+	}
+	options, err := parseCountOptions(f)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		return exitUsage
+	}
+	settings := runSettings{
+		outPath:  *f.outPath,
+		timeout:  *f.timeout,
+		watch:    *f.watch,
+		progress: *f.progress,
+		timings:  *f.timings,
 	}
-	var recipeDeliveryInput []recipeDelivery
-	err = json.Unmarshal([]byte(fileContent), &recipeDeliveryInput)
+
+	// stops counting cleanly on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *f.watch {
+		watcher := fileWatcher{
+			path:     options.filePath,
+			interval: *f.watchInterval,
+			debounce: *f.watchDebounce,
+		}
+		watcher.watch(ctx.Done(), func() {
+			// a failed run keeps the watcher alive, as the file might still be half-written
+			if _, err := countAndWrite(ctx, options, settings); err != nil {
+				log.Println(err)
+			}
+		})
+		return exitOK
+	}
+
+	response, err := countAndWrite(ctx, options, settings)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		return exitError
+	}
+	if response.Incomplete {
+		log.Println("counting was stopped before the end, the output is incomplete")
+		return exitIncomplete
 	}
+	if !response.CountPerPostcodeTime.Found {
+		log.Printf("no deliveries found for postcode %s", options.postcode)
+		return exitNoData
+	}
+	return exitOK
+}
 
-	// slices input for parallel processing
-	numCPU := runtime.NumCPU()
-	blocksize := len(recipeDeliveryInput) / numCPU
-	c := make(chan partialCountSets)
-	for i := 0; i < numCPU; i++ {
-		start, end := i*blocksize, (i+1)*blocksize
-		go partialCountRecipeDelivery(recipeDeliveryInput[start:end], options, c)
+// parseCountOptions converts the parsed count flags into the counting options, checking they can be used together.
+func parseCountOptions(f countFlags) (recipeCountOptions, error) {
+	options, err := parseSearchOptions(*f.filePath, *f.postcode, *f.deliveryTime, *f.recipeNames)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	options.queries, err = parsePostcodeQueries(*f.queries, options.delivery)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	options.approx, err = parseApproxOptions(*f.approx, *f.approxPrecision, *f.approxEpsilon, *f.approxDelta, *f.approxTop)
+	if err != nil {
+		return recipeCountOptions{}, err
 	}
+	options.groupBy, err = parseGroupByOptions(*f.groupBy, *f.groupSort, *f.groupLimit)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	options.where, err = parseDeliveryFilter(*f.where)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	if len(*f.catalog) > 0 {
+		if options.catalog, err = loadRecipeCatalog(*f.catalog); err != nil {
+			return recipeCountOptions{}, err
+		}
+	}
+	if len(*f.regions) > 0 {
+		if options.approx.enabled {
+			return recipeCountOptions{}, errors.New("regions are rolled up from exact postcode counts, and can't be used with approx")
+		}
+		if options.regions, err = loadPostcodeRegions(*f.regions); err != nil {
+			return recipeCountOptions{}, err
+		}
+	}
+	if len(*f.capacity) > 0 {
+		if options.capacity, err = loadDeliveryCapacity(*f.capacity); err != nil {
+			return recipeCountOptions{}, err
+		}
+		if options.capacity.needsRegions() && options.regions == nil {
+			return recipeCountOptions{}, errors.New("capacity of regions needs the regions of postcodes, given with -regions")
+		}
+	}
+	options.peaks, err = parsePeakOptions(*f.peakPostcodes)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	if *f.narrowWindow <= 0 {
+		return recipeCountOptions{}, errors.New("narrow window must be a positive duration")
+	}
+	options.narrowWindow = *f.narrowWindow
+	if *f.busiestTop < 1 {
+		return recipeCountOptions{}, errors.New("busiest top must be at least 1")
+	}
+	options.busiestTop = *f.busiestTop
+	options.metrics, err = parseMetricsOptions(*f.metrics)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	options.collate, err = parseCollateOptions(*f.collate)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	options.recipeOrder, err = parseRecipeOrderOptions(*f.recipeSort, *f.recipeLimit, *f.recipeOffset)
+	if err != nil {
+		return recipeCountOptions{}, err
+	}
+	if options.approx.enabled && options.recipeOrder.sort == recipeSortCountAsc {
+		return recipeCountOptions{}, errors.New("the long tail of recipes isn't known with approx, which only keeps the top recipes, so count-asc can't be used with it")
+	}
+	options.ignoreAccents = *f.ignoreAccents
+	options.partial = *f.partial
+	if err := checkMetrics(options); err != nil {
+		return recipeCountOptions{}, err
+	}
+	return options, nil
+}
 
-	// merges partial counts into totals set
-	recipeCountTotal := make(recipeCountSet, 0)
-	postcodeCountTotal := make(postcodeCountSet, 0)
-	partialCount := make([]partialCountSets, numCPU)
-	for i := range partialCount {
-		partialCount[i] = <-c
-		recipeCountTotal.merge(partialCount[i].recipeCountSet)
-		postcodeCountTotal.merge(partialCount[i].postcodeCountSet)
+// countFlags holds the values of the count command line flags, once parsed.
+type countFlags struct {
+	filePath        *string
+	postcode        *string
+	deliveryTime    *string
+	recipeNames     *string
+	queries         *string
+	ignoreAccents   *bool
+	collate         *string
+	recipeSort      *string
+	recipeLimit     *int
+	recipeOffset    *int
+	where           *string
+	outPath         *string
+	watch           *bool
+	watchInterval   *time.Duration
+	watchDebounce   *time.Duration
+	approx          *bool
+	approxPrecision *uint
+	approxEpsilon   *float64
+	approxDelta     *float64
+	approxTop       *int
+	groupBy         *string
+	groupSort       *string
+	groupLimit      *int
+	metrics         *string
+	catalog         *string
+	regions         *string
+	capacity        *string
+	peakPostcodes   *string
+	narrowWindow    *time.Duration
+	busiestTop      *int
+	timeout         *time.Duration
+	partial         *bool
+	progress        *bool
+	timings         *bool
+}
+
+func newCountFlagSet() (*flag.FlagSet, countFlags) {
+	flags := flag.NewFlagSet("count", flag.ContinueOnError)
+	setUsage(flags, "count [options]", "Counts the deliveries in the fixtures data file and writes the JSON report to stdout.\n"+
+		"Options can also be set in the -config file or as RECIPE_COUNT_* environment variables.")
+	flags.String("config", "", "YAML or JSON config file path, setting any of these options by name")
+	return flags, countFlags{
+		filePath:        flags.String("file", "", "fixtures data file path (required)"),
+		postcode:        flags.String("postcode", postcodeDefault, "postcode to search for"),
+		deliveryTime:    flags.String("time", deliveryTimeDefault, "delivery time to search for"),
+		recipeNames:     flags.String("recipes", recipeNamesDefault, "recipe(s) name(s) to search for, separated by commas"),
+		queries:         flags.String("queries", "", "further postcodes to search for, separated by commas, each optionally followed by @ and its delivery time, e.g. 10120@10AM-3PM"),
+		ignoreAccents:   flags.Bool("ignore-accents", false, "searches recipe names ignoring accents, e.g. jalapeno matching Jalapeño"),
+		collate:         flags.String("collate", "", "sorts recipe names with the collation rules of the given locale, e.g. fr or de (by bytes by default)"),
+		recipeSort:      flags.String("recipe-sort", "", "orders count_per_recipe by name, count or count-asc (by name, or by count in approx mode, by default)"),
+		recipeLimit:     flags.Int("recipe-limit", 0, "maximum number of recipes in count_per_recipe (all by default)"),
+		recipeOffset:    flags.Int("recipe-offset", 0, "number of recipes skipped at the start of count_per_recipe"),
+		where:           flags.String("where", "", "only counts deliveries matching the expression, e.g. \"weekday >= Saturday and recipe contains Chicken\""),
+		outPath:         flags.String("out", "", "output file path (defaults to stdout)"),
+		watch:           flags.Bool("watch", false, "recomputes the output every time the fixtures data file changes"),
+		watchInterval:   flags.Duration("watch-interval", watchIntervalDefault, "how often the fixtures data file is checked for changes"),
+		watchDebounce:   flags.Duration("watch-debounce", watchDebounceDefault, "how long the fixtures data file must stay unchanged before recomputing"),
+		approx:          flags.Bool("approx", false, "estimates counts with bounded-memory sketches instead of counting every postcode exactly"),
+		approxPrecision: flags.Uint("approx-precision", approxPrecisionDefault, "HyperLogLog precision for unique counts, between 4 and 18"),
+		approxEpsilon:   flags.Float64("approx-epsilon", approxEpsilonDefault, "Count-Min Sketch error, as a fraction of the total deliveries"),
+		approxDelta:     flags.Float64("approx-delta", approxDeltaDefault, "Count-Min Sketch probability of exceeding the error"),
+		approxTop:       flags.Int("approx-top", approxTopDefault, "number of top recipes and postcodes tracked"),
+		groupBy:         flags.String("group-by", "", "counts deliveries per group of any of: recipe,postcode,weekday,hour,window, separated by commas"),
+		groupSort:       flags.String("group-sort", groupSortCount, "orders groups by count, count-asc or group"),
+		groupLimit:      flags.Int("group-limit", 0, "maximum number of groups in the output (all by default)"),
+		metrics:         flags.String("metrics", "", "enables metrics by name on top of the default ones, any of: "+strings.Join(metricNames(), ",")),
+		catalog:         flags.String("catalog", "", "CSV or JSON recipe catalog file path, mapping recipe names and aliases to canonical recipes and categories"),
+		regions:         flags.String("regions", "", "CSV or JSON regions file path, mapping postcodes or prefixes such as 101* to city, region and depot"),
+		capacity:        flags.String("capacity", "", "CSV or JSON capacity file path, capping the deliveries of postcodes or regions per weekday and hour"),
+		peakPostcodes:   flags.String("peak-postcodes", "", "reports the peak concurrent delivery windows of the given postcodes, separated by commas, or * for all"),
+		narrowWindow:    flags.Duration("narrow-window", narrowWindowDefault, "widest delivery window counted as narrow by the windows metric"),
+		busiestTop:      flags.Int("busiest-top", busiestTopDefault, "number of busiest postcodes ranked per weekday and start hour by the busiest metric"),
+		timeout:         flags.Duration("timeout", 0, "stops counting after the given duration (no timeout by default)"),
+		partial:         flags.Bool("partial", false, "outputs the counts so far, flagged as incomplete, when stopped by a timeout or signal"),
+		progress:        flags.Bool("progress", false, "reports bytes read, records processed and records/s to stderr while running"),
+		timings:         flags.Bool("timings", false, "reports the duration of the read, decode, count and merge phases to stderr when done"),
 	}
+}
 
-	// outputs JSON response to stdout
-	response := buildCountResponse(recipeCountTotal, postcodeCountTotal, options)
-	printer := json.NewEncoder(os.Stdout)
-	printer.Encode(response)
+// runSettings holds how a count is run and output, as opposed to recipeCountOptions holding what is counted.
+type runSettings struct {
+	outPath  string
+	timeout  time.Duration
+	watch    bool
+	progress bool
+	timings  bool
 }
 
-type partialCountSets struct {
-	recipeCountSet   recipeCountSet
-	postcodeCountSet postcodeCountSet
+// countAndWrite counts and writes the response, reporting progress and timings to stderr only,
+// so that the JSON response is the only output on stdout.
+func countAndWrite(ctx context.Context, options recipeCountOptions, settings runSettings) (recipeCountResponse, error) {
+	if settings.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.timeout)
+		defer cancel()
+	}
+	// runs that a timeout or the watcher stops must not leave decoding running in the background
+	options.stoppable = settings.timeout > 0 || settings.watch
+	if settings.progress || settings.timings {
+		options.stats = newPipelineStats()
+	}
+	if settings.progress {
+		stopProgress := options.stats.reportProgress(os.Stderr, progressIntervalDefault)
+		defer stopProgress()
+	}
+
+	response, err := countFile(ctx, options)
+	if err != nil {
+		return response, err
+	}
+	err = writeCountResponse(response, settings.outPath)
+	if settings.timings {
+		options.stats.printTimings(os.Stderr)
+	}
+	return response, err
 }
 
-func partialCountRecipeDelivery(recipeDeliveryPart []recipeDelivery, options recipeCountOptions, c chan partialCountSets) {
-	recipeCountSet, postcodeCountSet := countRecipeDelivery(recipeDeliveryPart, options)
-	c <- partialCountSets{recipeCountSet, postcodeCountSet}
+// writeCountResponse outputs the JSON response to stdout, or to outPath when given.
+// Files are written to a temporary sibling first and then renamed, so readers never see a partial report.
+func writeCountResponse(response recipeCountResponse, outPath string) error {
+	if len(outPath) == 0 {
+		printer := json.NewEncoder(os.Stdout)
+		return printer.Encode(response)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	printer := json.NewEncoder(tmp)
+	if err := printer.Encode(response); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outPath)
 }
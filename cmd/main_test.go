@@ -1,25 +1,307 @@
 package main
 
 import (
-	"os"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestIntegration(t *testing.T) {
 	tests := map[string]func(*testing.T){
 		"should finish succesfully": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should read options from config file": func(t *testing.T) {
 			// given
-			os.Args = []string{"sherlock", "--file", "../data/demo.json"}
+			configPath := writeConfigFile(t, "config.yaml", "file: ../data/demo.json\npostcode: \"00000\"\n")
+
+			// when
+			code := run([]string{"--config", configPath})
+
+			// then
+			assert.Equal(t, exitNoData, code)
+		},
+		"should print config": func(t *testing.T) {
+			// when
+			code := run([]string{"config", "print", "--postcode", "00000"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should count with explicit command": func(t *testing.T) {
+			// when
+			code := run([]string{"count", "--file", "../data/demo.json"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should validate file": func(t *testing.T) {
+			// when
+			code := run([]string{"validate", "--file", "../data/demo.json"})
 
 			// then
-			main()
+			assert.Equal(t, exitOK, code)
 		},
-		"should panic when file is not found": func(t *testing.T) {
+		"should report invalid records": func(t *testing.T) {
 			// given
-			os.Args = []string{"sherlock", "--file", "file/not/found"}
+			filePath := filepath.Join(t.TempDir(), "invalid.json")
+			ioutil.WriteFile(filePath, []byte(`[{"postcode": "", "recipe": "Jam", "delivery": "Monday 9AM - 5PM"}]`), 0644)
+
+			// when
+			code := run([]string{"validate", "--file", filePath})
+
+			// then
+			assert.Equal(t, exitInvalid, code)
+		},
+		"should show help": func(t *testing.T) {
+			// when
+			code := run([]string{"help"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should fail on unknown command": func(t *testing.T) {
+			// when
+			code := run([]string{"serve"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should fail when file is not found": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "file/not/found"})
+
+			// then
+			assert.Equal(t, exitError, code)
+		},
+		"should fail when file is missing": func(t *testing.T) {
+			// when
+			code := run([]string{"--postcode", "10120"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should report no data when postcode is not found": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--postcode", "00000"})
+
+			// then
+			assert.Equal(t, exitNoData, code)
+		},
+		"should report no data when filter excludes postcode": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--where", "postcode != 10120"})
+
+			// then
+			assert.Equal(t, exitNoData, code)
+		},
+		"should count with catalog": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--catalog", "../data/catalog.csv"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should fail on missing catalog": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--catalog", "../data/missing.csv"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should count with regions": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--regions", "../data/regions.csv"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should fail on regions in approx mode": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--regions", "../data/regions.csv", "--approx"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should count with capacity": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--regions", "../data/regions.csv", "--capacity", "../data/capacity.csv"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should fail on the capacity metric without a capacity file": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--metrics", "capacity"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should fail on region capacity without regions": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--capacity", "../data/capacity.csv"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should count with distribution": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--metrics", "distribution"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should fail on distribution in approx mode": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--metrics", "distribution", "--approx"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should fail on metrics counting per postcode or recipe in approx mode": func(t *testing.T) {
+			// when
+			busiest := run([]string{"--file", "../data/demo.json", "--metrics", "busiest,windows", "--approx"})
+			groups := run([]string{"--file", "../data/demo.json", "--group-by", "postcode", "--approx"})
+			catalog := run([]string{"--file", "../data/demo.json", "--catalog", "../data/catalog.csv", "--approx"})
+
+			// then
+			assert.Equal(t, exitUsage, busiest)
+			assert.Equal(t, exitUsage, groups)
+			assert.Equal(t, exitUsage, catalog)
+		},
+		"should fail on the long tail of recipes in approx mode": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--recipe-sort", "count-asc", "--approx"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should fail on a narrow window that isn't positive": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--metrics", "windows", "--narrow-window", "0s"})
 
 			// then
-			assertPanic(t, main)
+			assert.Equal(t, exitUsage, code)
+		},
+		"should recommend a delivery window": func(t *testing.T) {
+			// when
+			code := run([]string{"recommend-window", "--file", "../data/demo.json", "--length", "4h"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should not recommend a delivery window for an absent postcode": func(t *testing.T) {
+			// when
+			code := run([]string{"recommend-window", "--file", "../data/demo.json", "--postcode", "00000"})
+
+			// then
+			assert.Equal(t, exitNoData, code)
+		},
+		"should fail on a busiest top below 1": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--metrics", "busiest", "--busiest-top", "0"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should fail on a metric named without the option it needs": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--metrics", "groups"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should fail on invalid filter": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--where", "colour = red"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+		"should report incomplete output when timed out": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--timeout", "1ns", "--partial"})
+
+			// then
+			assert.Equal(t, exitIncomplete, code)
+		},
+		"should fail when timed out without partial output": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--timeout", "1ns"})
+
+			// then
+			assert.Equal(t, exitError, code)
+		},
+		"should report no data when file is empty": func(t *testing.T) {
+			// given
+			filePath := filepath.Join(t.TempDir(), "empty.json")
+			ioutil.WriteFile(filePath, []byte("\n"), 0644)
+
+			// when
+			code := run([]string{"--file", filePath})
+
+			// then
+			assert.Equal(t, exitNoData, code)
+		},
+		"should count with queries": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--queries", "10120@9AM-4PM,10208"})
+
+			// then
+			assert.Equal(t, exitOK, code)
+		},
+		"should fail on invalid queries": func(t *testing.T) {
+			// when
+			code := run([]string{"--file", "../data/demo.json", "--queries", "10120@noon"})
+
+			// then
+			assert.Equal(t, exitUsage, code)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestParseCountOptions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should convert count flags into options": func(t *testing.T) {
+			// given
+			flags, f := newCountFlagSet()
+			flags.Parse([]string{"--file", "../data/demo.json", "--group-by", "weekday", "--busiest-top", "3", "--partial"})
+
+			// when
+			options, err := parseCountOptions(f)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, "../data/demo.json", options.filePath)
+			assert.Equal(t, postcodeDefault, options.postcode)
+			assert.True(t, options.groupBy.enabled())
+			assert.Equal(t, 3, options.busiestTop)
+			assert.True(t, options.partial)
+		},
+		"should fail on the first option that can't be used": func(t *testing.T) {
+			// given
+			flags, f := newCountFlagSet()
+			flags.Parse([]string{"--file", "../data/demo.json", "--approx", "--recipe-sort", "count-asc"})
+
+			// when
+			options, err := parseCountOptions(f)
+
+			// then
+			assert.Error(t, err)
+			assert.Equal(t, recipeCountOptions{}, options)
 		},
 	}
 
@@ -30,11 +312,39 @@ func TestIntegration(t *testing.T) {
 	}
 }
 
-func assertPanic(t *testing.T, f func()) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("a panic was expected")
-		}
-	}()
-	f()
+func TestWriteCountResponse(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should write response to out file": func(t *testing.T) {
+			// given
+			outPath := filepath.Join(t.TempDir(), "report.json")
+			response := recipeCountResponse{UniqueRecipeCount: 7}
+
+			// when
+			err := writeCountResponse(response, outPath)
+
+			// then
+			content, _ := ioutil.ReadFile(outPath)
+			files, _ := ioutil.ReadDir(filepath.Dir(outPath))
+			assert.NoError(t, err)
+			assert.Contains(t, string(content), `"unique_recipe_count":7`)
+			assert.Equal(t, 1, len(files))
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func BenchmarkDecodeFixtures(b *testing.B) {
+	fixtures := benchmarkFixtures(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(fixtures)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var records []recipeDelivery
+		json.Unmarshal(fixtures, &records)
+	}
 }
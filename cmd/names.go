@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameSearch matches recipe names containing any of the searched names, comparing them with Unicode case folding
+// so that "STRASSE" matches "Straße". When ignoring accents, diacritics are also stripped after NFKD normalization,
+// so that "jalapeno" matches "Jalapeño".
+type nameSearch struct {
+	names         []string
+	ignoreAccents bool
+}
+
+func newNameSearch(names []string, ignoreAccents bool) nameSearch {
+	s := nameSearch{make([]string, len(names)), ignoreAccents}
+	for i, n := range names {
+		s.names[i] = s.fold(n)
+	}
+	return s
+}
+
+// fold returns the form names are compared in, which is not meant to be displayed.
+func (s nameSearch) fold(name string) string {
+	if s.ignoreAccents {
+		name = stripAccents(name)
+	} else {
+		// composed and decomposed accents are compared alike
+		name = norm.NFC.String(name)
+	}
+	return cases.Fold().String(name)
+}
+
+func (s nameSearch) matches(recipe string) bool {
+	recipeName := s.fold(recipe)
+	for _, n := range s.names {
+		if strings.Contains(recipeName, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func stripAccents(name string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(t, name)
+	if err != nil {
+		return name
+	}
+	return stripped
+}
+
+// parseCollateOptions checks the locale recipe names are sorted by, where no locale keeps them sorted by bytes.
+func parseCollateOptions(locale string) (string, error) {
+	if len(locale) == 0 {
+		return "", nil
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("unknown collate locale %q", locale)
+	}
+	return tag.String(), nil
+}
+
+// collate sorts the list by recipe name with the collation rules of locale, e.g. sorting "Éclair" before
+// "Zucchini" and ignoring case first. The list is left as is when locale is empty, so it must already be sorted
+// by bytes, which also breaks ties between names the locale compares as equal.
+func (l recipeCountList) collate(locale string) {
+	if len(locale) == 0 {
+		return
+	}
+	collator := collate.New(language.Make(locale))
+	sort.SliceStable(l, func(i, j int) bool {
+		return collator.CompareString(l[i].Recipe, l[j].Recipe) < 0
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameSearch(t *testing.T) {
+	recipes := []string{
+		"Käsespätzle mit Röstzwiebeln",
+		"Straßenküche Currywurst",
+		"Crème Brûlée",
+		"Bœuf Bourguignon",
+		"Jalapeño Poppers",
+		"Χοιρινό Σουβλάκι",
+	}
+	matching := func(search nameSearch) []string {
+		list := make([]string, 0)
+		for _, r := range recipes {
+			if search.matches(r) {
+				list = append(list, r)
+			}
+		}
+		return list
+	}
+
+	tests := map[string]func(*testing.T){
+		"should match with unicode case folding": func(t *testing.T) {
+			// when
+			search := newNameSearch([]string{"KÄSESPÄTZLE", "STRASSENKÜCHE", "σουβλάκι"}, false)
+
+			// then
+			assert.Equal(t, []string{"Käsespätzle mit Röstzwiebeln", "Straßenküche Currywurst", "Χοιρινό Σουβλάκι"}, matching(search))
+		},
+		"should match accents exactly by default, composed or not": func(t *testing.T) {
+			// when
+			search := newNameSearch([]string{"jalapeno", "creme brulee", "Cre\u0300me"}, false)
+
+			// then
+			assert.Equal(t, []string{"Crème Brûlée"}, matching(search))
+		},
+		"should match ignoring accents": func(t *testing.T) {
+			// when
+			search := newNameSearch([]string{"jalapeno", "creme brulee", "spatzle", "σουβλακι"}, true)
+
+			// then
+			assert.Equal(t, []string{"Käsespätzle mit Röstzwiebeln", "Crème Brûlée", "Jalapeño Poppers", "Χοιρινό Σουβλάκι"}, matching(search))
+		},
+		"should strip accents from searched names too": func(t *testing.T) {
+			// when
+			search := newNameSearch([]string{"Bœuf Bourguignon", "Jalapeño"}, true)
+
+			// then
+			assert.False(t, search.matches("boeuf bourguignon"))
+			assert.True(t, search.matches("Jalapeno Poppers"))
+			assert.Equal(t, []string{"Bœuf Bourguignon", "Jalapeño Poppers"}, matching(search))
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestCollate(t *testing.T) {
+	set := recipeCountSet{"Zucchini Fritters": 1, "apple Pie": 1, "Éclair au Chocolat": 2, "Bœuf Bourguignon": 1}
+
+	tests := map[string]func(*testing.T){
+		"should sort by bytes without locale": func(t *testing.T) {
+			// given
+			list := set.toSortedList()
+
+			// when
+			list.collate("")
+
+			// then
+			assert.Equal(t, []string{"Bœuf Bourguignon", "Zucchini Fritters", "apple Pie", "Éclair au Chocolat"}, list.filterByNames(newNameSearch([]string{""}, false)))
+		},
+		"should sort with the locale collation": func(t *testing.T) {
+			// given
+			list := set.toSortedList()
+
+			// when
+			list.collate("fr")
+
+			// then
+			assert.Equal(t, []string{"apple Pie", "Bœuf Bourguignon", "Éclair au Chocolat", "Zucchini Fritters"}, list.filterByNames(newNameSearch([]string{""}, false)))
+			assert.Equal(t, 2, list[2].DeliveryCount)
+		},
+		"should sort count per recipe and matches by name": func(t *testing.T) {
+			// given
+			locale, err := parseCollateOptions("de-DE")
+			recipes := &recipeAggregator{set: set, search: newNameSearch([]string{"pie", "chocolat"}, false), collate: locale}
+			response := recipeCountResponse{}
+
+			// when
+			recipes.result(&response)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, "apple Pie", response.CountPerRecipe[0].Recipe)
+			assert.Equal(t, []string{"apple Pie", "Éclair au Chocolat"}, response.MatchByName)
+		},
+		"should not parse invalid locales": func(t *testing.T) {
+			// when
+			_, err := parseCollateOptions("not a locale")
+
+			// then
+			assert.Error(t, err)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// selects every postcode for occupancy profiles
+const peakPostcodesAll string = "*"
+
+const hoursPerWeek int = 7 * 24
+
+// peakOptions selects the postcodes occupancy profiles are built for, where nil postcodes select all of them.
+type peakOptions struct {
+	enabled   bool
+	postcodes map[string]bool
+}
+
+func parsePeakOptions(postcodes string) (peakOptions, error) {
+	postcodes = strings.TrimSpace(postcodes)
+	if len(postcodes) == 0 {
+		return peakOptions{}, nil
+	}
+	if postcodes == peakPostcodesAll {
+		return peakOptions{enabled: true}, nil
+	}
+
+	options := peakOptions{enabled: true, postcodes: make(map[string]bool)}
+	for _, p := range strings.Split(postcodes, ",") {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			options.postcodes[p] = true
+		}
+	}
+	if len(options.postcodes) == 0 {
+		return peakOptions{}, errors.New("peak postcodes must list postcodes separated by commas, or * for all of them")
+	}
+	return options, nil
+}
+
+// all reports whether every postcode is selected, with *.
+func (o peakOptions) all() bool {
+	return o.enabled && o.postcodes == nil
+}
+
+func (o peakOptions) selects(postcode string) bool {
+	return o.postcodes == nil || o.postcodes[postcode]
+}
+
+// occupancyProfile counts the delivery windows open in every hour of the week. It holds the change in open
+// windows at every hour rather than the open windows themselves, so that adding a window takes two updates
+// whatever its length, and the open windows are only summed up once merged.
+type occupancyProfile [hoursPerWeek + 1]int32
+
+// add opens the window for every hour it overlaps.
+func (p *occupancyProfile) add(w deliveryWindow) {
+	start, end, ok := w.openHours()
+	if !ok {
+		return
+	}
+	p[start]++
+	p[end]--
+}
+
+// openHours returns the hours of the week the window overlaps, from start to end excluded, where windows ending
+// at 12AM end at midnight. Other windows ending before they start overlap none.
+func (w deliveryWindow) openHours() (int, int, bool) {
+	start, end := w.start/60, (w.end+59)/60
+	if end <= start && w.end%minutesPerDay == 0 {
+		end += 24
+	}
+	return start, end, end > start
+}
+
+func (p *occupancyProfile) merge(o *occupancyProfile) {
+	for i := range p {
+		p[i] += o[i]
+	}
+}
+
+// occupancy returns the open windows in every hour of the week.
+func (p *occupancyProfile) occupancy() [hoursPerWeek]int {
+	var open [hoursPerWeek]int
+	current := 0
+	for hour := range open {
+		current += int(p[hour])
+		open[hour] = current
+	}
+	return open
+}
+
+// peakAggregator builds an occupancy profile per selected postcode. Every profile takes about 700 bytes,
+// so selecting every postcode of large inputs takes a lot of memory.
+type peakAggregator struct {
+	profiles map[string]*occupancyProfile
+	options  peakOptions
+}
+
+func newPeakAggregator(options recipeCountOptions) aggregator {
+	return &peakAggregator{make(map[string]*occupancyProfile), options.peaks}
+}
+
+func (a *peakAggregator) add(r *deliveryRecord) {
+	if !a.options.selects(r.Postcode) {
+		return
+	}
+	window, ok := r.deliveryWindow()
+	if !ok {
+		return
+	}
+	profile, exists := a.profiles[r.Postcode]
+	if !exists {
+		profile = new(occupancyProfile)
+		a.profiles[r.Postcode] = profile
+	}
+	profile.add(window)
+}
+
+func (a *peakAggregator) merge(o aggregator) {
+	for postcode, profile := range o.(*peakAggregator).profiles {
+		if total, exists := a.profiles[postcode]; exists {
+			total.merge(profile)
+		} else {
+			a.profiles[postcode] = profile
+		}
+	}
+}
+
+func (a *peakAggregator) result(response *recipeCountResponse) {
+	peaks := make([]postcodePeak, 0, len(a.profiles))
+	for postcode, profile := range a.profiles {
+		peak := postcodePeak{
+			Postcode:  postcode,
+			PeakHours: make([]occupancyHour, 0),
+			Occupancy: make([]occupancyHour, 0),
+		}
+		open := profile.occupancy()
+		for hour, count := range open {
+			if count > peak.PeakConcurrentCount {
+				peak.PeakConcurrentCount = count
+				peak.PeakHours = peak.PeakHours[:0]
+			}
+			if count > 0 && count == peak.PeakConcurrentCount {
+				peak.PeakHours = append(peak.PeakHours, newOccupancyHour(hour, count))
+			}
+			if count > 0 {
+				peak.Occupancy = append(peak.Occupancy, newOccupancyHour(hour, count))
+			}
+		}
+		peaks = append(peaks, peak)
+	}
+	sort.Slice(peaks, func(i, j int) bool {
+		return peaks[i].Postcode < peaks[j].Postcode
+	})
+	response.PeakPerPostcode = peaks
+}
+
+// postcodePeak is the most delivery windows open at the same time for a postcode, along with every hour
+// they are, and the open windows of every hour with any.
+type postcodePeak struct {
+	Postcode            string          `json:"postcode"`
+	PeakConcurrentCount int             `json:"peak_concurrent_count"`
+	PeakHours           []occupancyHour `json:"peak_hours"`
+	Occupancy           []occupancyHour `json:"occupancy"`
+}
+
+type occupancyHour struct {
+	Weekday     string `json:"weekday"`
+	Hour        string `json:"hour"`
+	WindowCount int    `json:"window_count"`
+}
+
+func newOccupancyHour(hourOfWeek int, count int) occupancyHour {
+	return occupancyHour{weekdays[hourOfWeek/24], formatHour(hourOfWeek % 24), count}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePeakOptions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should parse selected postcodes": func(t *testing.T) {
+			// when
+			none, errNone := parsePeakOptions("")
+			all, errAll := parsePeakOptions(" * ")
+			selected, errSelected := parsePeakOptions("10120, 10208,")
+			_, errEmpty := parsePeakOptions(" , ")
+
+			// then
+			assert.NoError(t, errNone)
+			assert.NoError(t, errAll)
+			assert.NoError(t, errSelected)
+			assert.Error(t, errEmpty)
+			assert.False(t, none.enabled)
+			assert.True(t, all.enabled)
+			assert.True(t, all.selects("99999"))
+			assert.True(t, selected.selects("10208"))
+			assert.False(t, selected.selects("99999"))
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestPeakAggregator(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 1PM - 5PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 2PM - 4PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Sunday 10PM - 12AM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Someday"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 11AM - 2PM"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should find the peak concurrent windows of selected postcodes across workers": func(t *testing.T) {
+			// given
+			options := recipeCountOptions{}
+			options.peaks, _ = parsePeakOptions("10120")
+
+			// when
+			response := aggregateInTwoWorkers(t, options, input, 2)
+
+			// then
+			assert.Len(t, response.PeakPerPostcode, 1)
+			peak := response.PeakPerPostcode[0]
+			assert.Equal(t, "10120", peak.Postcode)
+			assert.Equal(t, 3, peak.PeakConcurrentCount)
+			assert.Equal(t, []occupancyHour{{Weekday: "Wednesday", Hour: "2PM", WindowCount: 3}}, peak.PeakHours)
+			assert.Equal(t, occupancyHour{Weekday: "Wednesday", Hour: "10AM", WindowCount: 1}, peak.Occupancy[0])
+			assert.Equal(t, occupancyHour{Weekday: "Sunday", Hour: "11PM", WindowCount: 1}, peak.Occupancy[len(peak.Occupancy)-1])
+			assert.Len(t, peak.Occupancy, 9)
+		},
+		"should build profiles for every postcode": func(t *testing.T) {
+			// given
+			options := recipeCountOptions{}
+			options.peaks, _ = parsePeakOptions(peakPostcodesAll)
+			aggregators := newAggregators(options)
+
+			// when
+			aggregate(context.Background(), input, options, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.Len(t, response.PeakPerPostcode, 2)
+			assert.Equal(t, "10208", response.PeakPerPostcode[1].Postcode)
+			assert.Len(t, response.PeakPerPostcode[1].PeakHours, 3)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
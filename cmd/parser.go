@@ -2,16 +2,67 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
 )
 
 type recipeCountOptions struct {
-	filePath string
-	postcode string
-	delivery deliveryPeriod
-	recipes  recipeSearchSet
+	filePath      string
+	postcode      string
+	delivery      deliveryPeriod
+	queries       []postcodeQuery
+	recipes       recipeSearchSet
+	ignoreAccents bool
+	collate       string
+	recipeOrder   recipeOrderOptions
+	approx        approxOptions
+	groupBy       groupByOptions
+	where         *deliveryFilter
+	metrics       []string
+	catalog       *recipeCatalog
+	regions       *postcodeRegions
+	capacity      *deliveryCapacity
+	peaks         peakOptions
+	narrowWindow  time.Duration
+	busiestTop    int
+	partial       bool
+	stoppable     bool
+	stats         *pipelineStats
+}
+
+func (o recipeCountOptions) nameSearch() nameSearch {
+	return newNameSearch(o.recipes.names(), o.ignoreAccents)
+}
+
+// orders count_per_recipe can be sorted by
+const (
+	recipeSortName     string = "name"
+	recipeSortCount    string = "count"
+	recipeSortCountAsc string = "count-asc"
+)
+
+// recipeOrderOptions sorts count_per_recipe and keeps a page of it, where a zero limit keeps every recipe.
+// The long tail of recipes is the first page sorted by count-asc. Without a sort, recipes are sorted by name,
+// or by count in approx mode, as only the top recipes are known then.
+type recipeOrderOptions struct {
+	sort   string
+	limit  int
+	offset int
+}
+
+func parseRecipeOrderOptions(sortBy string, limit int, offset int) (recipeOrderOptions, error) {
+	if sortBy != "" && sortBy != recipeSortName && sortBy != recipeSortCount && sortBy != recipeSortCountAsc {
+		return recipeOrderOptions{}, fmt.Errorf("unknown recipe sort %q, expected one of: name, count, count-asc", sortBy)
+	}
+	if limit < 0 {
+		return recipeOrderOptions{}, errors.New("recipe limit must not be negative")
+	}
+	if offset < 0 {
+		return recipeOrderOptions{}, errors.New("recipe offset must not be negative")
+	}
+	return recipeOrderOptions{sortBy, limit, offset}, nil
 }
 
 type deliveryPeriod struct {
@@ -23,6 +74,108 @@ func (p deliveryPeriod) includes(o deliveryPeriod) bool {
 	return !o.start.Before(p.start) && !o.end.After(p.end)
 }
 
+// minutes returns the period start and end as minutes since 12AM, to be compared with a deliveryWindow.
+func (p deliveryPeriod) minutes() (int, int) {
+	return p.start.Hour()*60 + p.start.Minute(), p.end.Hour()*60 + p.end.Minute()
+}
+
+const minutesPerDay int = 24 * 60
+
+var weekdays = [...]string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+// deliveryWindow is the compact form of a record delivery, in minutes since Monday 12AM.
+// Both ends fall on the delivery weekday, as in "Monday 9AM - 5PM".
+type deliveryWindow struct {
+	start int
+	end   int
+}
+
+func (w deliveryWindow) weekday() int {
+	return w.start / minutesPerDay
+}
+
+// includedIn mirrors deliveryPeriod.includes, for a period given in minutes since 12AM.
+func (w deliveryWindow) includedIn(start int, end int) bool {
+	return w.start%minutesPerDay >= start && w.end%minutesPerDay <= end
+}
+
+// scanDeliveryWindow is the hot path counterpart of parseDeliveryPeriod. It scans strings formatted as
+// "{weekday} {h}AM - {h}PM" without allocating, and is stricter: the weekday must be spelled as in weekdays,
+// and nothing else may surround the delivery nor split its hours.
+func scanDeliveryWindow(delivery string) (deliveryWindow, bool) {
+	i := 0
+	for i < len(delivery) && delivery[i] != ' ' {
+		i++
+	}
+	weekday := -1
+	for d, name := range weekdays {
+		if delivery[:i] == name {
+			weekday = d
+			break
+		}
+	}
+	if weekday < 0 {
+		return deliveryWindow{}, false
+	}
+
+	start, i, ok := scanHour(delivery, skipSpaces(delivery, i))
+	if !ok {
+		return deliveryWindow{}, false
+	}
+	i = skipSpaces(delivery, i)
+	if i >= len(delivery) || delivery[i] != '-' {
+		return deliveryWindow{}, false
+	}
+	end, i, ok := scanHour(delivery, skipSpaces(delivery, i+1))
+	if !ok || skipSpaces(delivery, i) != len(delivery) {
+		return deliveryWindow{}, false
+	}
+
+	offset := weekday * minutesPerDay
+	return deliveryWindow{
+		start: offset + start,
+		end:   offset + end,
+	}, true
+}
+
+// scanHour scans an hour such as "9AM" or "12 PM" at position i, returning it as minutes since 12AM
+// along with the position right after it.
+func scanHour(s string, i int) (int, int, bool) {
+	if i >= len(s) || s[i] < '1' || s[i] > '9' {
+		return 0, i, false
+	}
+	hour := int(s[i] - '0')
+	i++
+	if i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		hour = hour*10 + int(s[i]-'0')
+		i++
+	}
+	if hour > 12 {
+		return 0, i, false
+	}
+
+	i = skipSpaces(s, i)
+	if i+1 >= len(s) || s[i+1] != 'M' {
+		return 0, i, false
+	}
+	switch s[i] {
+	case 'A':
+		hour = hour % 12
+	case 'P':
+		hour = hour%12 + 12
+	default:
+		return 0, i, false
+	}
+	return hour * 60, i + 2, true
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	return i
+}
+
 type recipeSearchSet map[string]bool
 
 func (s recipeSearchSet) add(recipe string) {
@@ -47,9 +200,10 @@ func (s recipeSearchSet) exists(recipe string) bool {
 	return s[recipe]
 }
 
+var deliveryTimeRegexp = regexp.MustCompile(`(1[012]|[1-9])(\\s)?(AM|PM)-(1[012]|[1-9])(\\s)?(AM|PM)`)
+
 func parseDeliveryPeriod(deliveryTime string) (deliveryPeriod, error) {
-	rexp := regexp.MustCompile(`(1[012]|[1-9])(\\s)?(AM|PM)-(1[012]|[1-9])(\\s)?(AM|PM)`)
-	timestamp := rexp.FindString(strings.ReplaceAll(deliveryTime, " ", ""))
+	timestamp := deliveryTimeRegexp.FindString(strings.ReplaceAll(deliveryTime, " ", ""))
 	if timestamp == "" {
 		return deliveryPeriod{}, errors.New("badly formatted delivery time string")
 	}
@@ -63,7 +217,8 @@ func parseDeliveryPeriod(deliveryTime string) (deliveryPeriod, error) {
 	}, nil
 }
 
-func parseCountOptions(filePath string, postcode string, deliveryTime string, recipeNames string) (recipeCountOptions, error) {
+// parseSearchOptions parses the file and what is searched in it, defaulting the searches left empty.
+func parseSearchOptions(filePath string, postcode string, deliveryTime string, recipeNames string) (recipeCountOptions, error) {
 	if len(filePath) == 0 {
 		return recipeCountOptions{}, errors.New("file is a required argument")
 	}
@@ -83,9 +238,9 @@ func parseCountOptions(filePath string, postcode string, deliveryTime string, re
 	recipeSearchSet.addBulk(recipeNames, ",")
 
 	return recipeCountOptions{
-		filePath,
-		postcode,
-		deliveryPeriod,
-		recipeSearchSet,
+		filePath: filePath,
+		postcode: postcode,
+		delivery: deliveryPeriod,
+		recipes:  recipeSearchSet,
 	}, nil
 }
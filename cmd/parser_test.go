@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -173,7 +176,7 @@ func TestIncludesDeliveryPeriod(t *testing.T) {
 	}
 }
 
-func TestParseCountOptions(t *testing.T) {
+func TestParseSearchOptions(t *testing.T) {
 	tests := map[string]func(*testing.T){
 		"should parse count options": func(t *testing.T) {
 			// given
@@ -183,7 +186,7 @@ func TestParseCountOptions(t *testing.T) {
 			recipeNames := "Potato,Pie"
 
 			// when
-			options, err := parseCountOptions(filePath, postcode, deliveryTime, recipeNames)
+			options, err := parseSearchOptions(filePath, postcode, deliveryTime, recipeNames)
 
 			// then
 			assert.Equal(t, "path/to/file.json", options.filePath)
@@ -198,7 +201,7 @@ func TestParseCountOptions(t *testing.T) {
 			filePath := "path/to/file.json"
 
 			// when
-			options, err := parseCountOptions(filePath, "", "", "")
+			options, err := parseSearchOptions(filePath, "", "", "")
 
 			// then
 			assert.Equal(t, "path/to/file.json", options.filePath)
@@ -216,7 +219,7 @@ func TestParseCountOptions(t *testing.T) {
 			recipeNames := "Potato,Pie"
 
 			// when
-			_, err := parseCountOptions(filePath, postcode, deliveryTime, recipeNames)
+			_, err := parseSearchOptions(filePath, postcode, deliveryTime, recipeNames)
 
 			// then
 			assert.Error(t, err)
@@ -229,3 +232,167 @@ func TestParseCountOptions(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkParseDeliveryPeriod(b *testing.B) {
+	records := benchmarkRecipeDelivery(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		parseDeliveryPeriod(records[n%len(records)].Delivery)
+	}
+}
+
+func TestScanDeliveryWindow(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should scan delivery window": func(t *testing.T) {
+			// when
+			window, ok := scanDeliveryWindow("Wednesday 10AM - 3PM")
+
+			// then
+			assert.True(t, ok)
+			assert.Equal(t, deliveryWindow{start: 2*minutesPerDay + 10*60, end: 2*minutesPerDay + 15*60}, window)
+			assert.Equal(t, 2, window.weekday())
+		},
+		"should scan midnight and noon": func(t *testing.T) {
+			// when
+			window, ok := scanDeliveryWindow("Monday 12AM - 12PM")
+
+			// then
+			assert.True(t, ok)
+			assert.Equal(t, deliveryWindow{start: 0, end: 12 * 60}, window)
+		},
+		"should not scan badly formatted delivery strings": func(t *testing.T) {
+			for _, delivery := range []string{"", "Wednesday", "Someday 10AM - 3PM", "Wednesday 13AM - 3PM", "Wednesday 10AM 3PM", "Wednesday 10AM - 3PM!", "Wednesday 0AM - 3PM", "Wednesday 10XM - 3PM"} {
+				// when
+				_, ok := scanDeliveryWindow(delivery)
+
+				// then
+				assert.False(t, ok, delivery)
+			}
+		},
+		"should reject on purpose the listed deliveries parseDeliveryPeriod accepts": func(t *testing.T) {
+			for _, exception := range scanDeliveryWindowExceptions {
+				// when
+				_, ok := scanDeliveryWindow(exception.example)
+				_, err := parseDeliveryPeriod(exception.example)
+
+				// then
+				assert.False(t, ok, exception.name)
+				assert.NoError(t, err, exception.name)
+				assert.True(t, exception.applies(exception.example), exception.name)
+			}
+		},
+		"should check window is included in period": func(t *testing.T) {
+			// given
+			period, _ := parseDeliveryPeriod("10AM-3PM")
+			start, end := period.minutes()
+			included, _ := scanDeliveryWindow("Sunday 10AM - 2PM")
+			notIncluded, _ := scanDeliveryWindow("Sunday 9AM - 2PM")
+
+			// then
+			assert.True(t, included.includedIn(start, end))
+			assert.False(t, notIncluded.includedIn(start, end))
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+// FuzzScanDeliveryWindow builds valid delivery strings and checks the scanner agrees with parseDeliveryPeriod.
+func FuzzScanDeliveryWindow(f *testing.F) {
+	f.Add(uint8(2), uint8(10), false, uint8(3), true, uint8(1))
+	f.Add(uint8(0), uint8(12), false, uint8(12), true, uint8(0))
+	f.Fuzz(func(t *testing.T, weekday uint8, startHour uint8, startPM bool, endHour uint8, endPM bool, spaces uint8) {
+		meridiem := map[bool]string{false: "AM", true: "PM"}
+		space := strings.Repeat(" ", int(spaces%3))
+		delivery := fmt.Sprintf("%s %d%s%s%s-%s%d%s%s",
+			weekdays[weekday%7], startHour%12+1, space, meridiem[startPM], space, space, endHour%12+1, space, meridiem[endPM])
+
+		window, ok := scanDeliveryWindow(delivery)
+		period, err := parseDeliveryPeriod(delivery)
+		start, end := period.minutes()
+
+		assert.NoError(t, err, delivery)
+		assert.True(t, ok, delivery)
+		assert.Equal(t, int(weekday%7), window.weekday(), delivery)
+		assert.Equal(t, start, window.start%minutesPerDay, delivery)
+		assert.Equal(t, end, window.end%minutesPerDay, delivery)
+	})
+}
+
+// scanDeliveryWindowExceptions lists the deliveries parseDeliveryPeriod accepts but scanDeliveryWindow rejects
+// on purpose, each with an example.
+var scanDeliveryWindowExceptions = []struct {
+	name    string
+	example string
+	applies func(delivery string) bool
+}{
+	{"missing weekday", "10AM - 3PM", func(delivery string) bool {
+		return len(delivery) > 0 && delivery[0] >= '0' && delivery[0] <= '9'
+	}},
+	{"unknown or lower-case weekday", "monday 10AM - 3PM", func(delivery string) bool {
+		word := strings.SplitN(delivery, " ", 2)[0]
+		for _, name := range weekdays {
+			if word == name {
+				return false
+			}
+		}
+		return true
+	}},
+	{"text around the delivery time", "Monday 10AM - 3PM, ring twice", func(delivery string) bool {
+		i := strings.IndexByte(delivery, ' ')
+		if i < 0 {
+			return true
+		}
+		timestamp := strings.ReplaceAll(delivery[i:], " ", "")
+		return deliveryTimeRegexp.FindString(timestamp) != timestamp
+	}},
+	{"spaces within hours or meridiems", "Monday 1 0AM - 3P M", regexp.MustCompile(`[0-9] +[0-9]|[AP] +M`).MatchString},
+	{"escaped spaces, which the regular expression takes literally", `Monday 10\sAM - 3PM`, func(delivery string) bool {
+		return strings.Contains(delivery, `\s`)
+	}},
+}
+
+// FuzzScanDeliveryWindowStrings checks the scanner accepts the same strings as parseDeliveryPeriod, apart from
+// scanDeliveryWindowExceptions, and reads them the same way.
+func FuzzScanDeliveryWindowStrings(f *testing.F) {
+	f.Add("Wednesday 10AM - 3PM")
+	f.Add("Saturday 1AM - 8PM")
+	f.Add("Monday 12 AM-12 PM")
+	f.Add("Someday 13XM - 25YM")
+	for _, exception := range scanDeliveryWindowExceptions {
+		f.Add(exception.example)
+	}
+	f.Fuzz(func(t *testing.T, delivery string) {
+		window, ok := scanDeliveryWindow(delivery)
+		period, err := parseDeliveryPeriod(delivery)
+		if !ok {
+			if err == nil {
+				excepted := false
+				for _, exception := range scanDeliveryWindowExceptions {
+					excepted = excepted || exception.applies(delivery)
+				}
+				assert.True(t, excepted, "parseDeliveryPeriod accepts %q, which the scanner rejects", delivery)
+			}
+			return
+		}
+		start, end := period.minutes()
+
+		assert.NoError(t, err, delivery)
+		assert.Equal(t, start, window.start%minutesPerDay, delivery)
+		assert.Equal(t, end, window.end%minutesPerDay, delivery)
+	})
+}
+
+func BenchmarkScanDeliveryWindow(b *testing.B) {
+	records := benchmarkRecipeDelivery(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		scanDeliveryWindow(records[n%len(records)].Delivery)
+	}
+}
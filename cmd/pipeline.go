@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+)
+
+// how many records a worker counts between checks for cancellation
+const cancelCheckInterval int = 4096
+
+// rough size of a record in the fixtures data file, used to preallocate the decoded records
+const recordSizeEstimate int = 90
+
+// interrupted reports whether err comes from a timeout or cancellation the options accept partial results for.
+func interrupted(err error, options recipeCountOptions) bool {
+	return options.partial && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// readRecipeDelivery reads and decodes the fixtures data file, returning ctx's error as soon as it is done.
+// Records are decoded one at a time when perRecord is set, so that decoding stops as soon as ctx is done.
+func readRecipeDelivery(ctx context.Context, filePath string, perRecord bool, stats *pipelineStats) ([]recipeDelivery, error) {
+	endRead := stats.phase("read")
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fileContent, err := ioutil.ReadAll(contextReader{ctx, countingReader{file, stats}})
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	endRead()
+
+	defer stats.phase("decode")()
+	return decodeRecipeDelivery(ctx, fileContent, perRecord, stats)
+}
+
+func decodeRecipeDelivery(ctx context.Context, fileContent []byte, perRecord bool, stats *pipelineStats) ([]recipeDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// an empty file holds no deliveries, rather than being badly formatted
+	if len(bytes.TrimSpace(fileContent)) == 0 {
+		return nil, nil
+	}
+
+	// decoding all records at once can't be stopped, so it is left running in the background when ctx is done,
+	// holding on to the file content, which only single runs about to exit can afford
+	if !perRecord && !stats.decodesPerRecord() {
+		decoded := make(chan error, 1)
+		var recipeDeliveryInput []recipeDelivery
+		go func() {
+			decoded <- json.Unmarshal(fileContent, &recipeDeliveryInput)
+		}()
+		select {
+		case err := <-decoded:
+			stats.addRecordsDecoded(len(recipeDeliveryInput))
+			return recipeDeliveryInput, err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(fileContent))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, nil
+	}
+	if token != json.Delim('[') {
+		return nil, errors.New("fixtures data must be an array of deliveries")
+	}
+	recipeDeliveryInput := make([]recipeDelivery, 0, len(fileContent)/recordSizeEstimate)
+	for i := 0; decoder.More(); i++ {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		var r recipeDelivery
+		if err := decoder.Decode(&r); err != nil {
+			return nil, err
+		}
+		recipeDeliveryInput = append(recipeDeliveryInput, r)
+		stats.addRecordsDecoded(1)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return recipeDeliveryInput, nil
+}
+
+// countFile counts the fixtures data file in parallel. When ctx is done before counting finishes, the
+// counts so far are returned flagged as incomplete if options.partial is set, otherwise ctx's error is.
+func countFile(ctx context.Context, options recipeCountOptions) (recipeCountResponse, error) {
+	recipeDeliveryInput, err := readRecipeDelivery(ctx, options.filePath, options.stoppable, options.stats)
+	if interrupted(err, options) {
+		// stopped before counting anything
+		response := buildAggregatedResponse(newAggregators(options))
+		response.Incomplete = true
+		return response, nil
+	}
+	if err != nil {
+		return recipeCountResponse{}, err
+	}
+
+	total, err := countInParallel(ctx, recipeDeliveryInput, options, runtime.NumCPU(), partialCountRecipeDelivery)
+	if err != nil && !interrupted(err, options) {
+		return recipeCountResponse{}, err
+	}
+	response := buildAggregatedResponse(total)
+	response.Incomplete = err != nil
+	return response, nil
+}
+
+// countWorker counts a block of the input, sending its partial aggregators to c once done or failed.
+type countWorker func(ctx context.Context, recipeDeliveryPart []recipeDelivery, options recipeCountOptions, c chan<- partialAggregators)
+
+// countInParallel slices input for the given number of workers and merges their partial aggregators,
+// stopping every worker on the first error, which is returned along with the counts so far.
+func countInParallel(ctx context.Context, recipeDeliveryInput []recipeDelivery, options recipeCountOptions, workers int, count countWorker) ([]aggregator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocksize := len(recipeDeliveryInput) / workers
+	c := make(chan partialAggregators, workers)
+	for i := 0; i < workers; i++ {
+		start, end := blockBounds(i, blocksize, workers, len(recipeDeliveryInput))
+		go count(ctx, recipeDeliveryInput[start:end], options, c)
+	}
+
+	var firstErr error
+	endCount := options.stats.phase("count")
+	partials := make([]partialAggregators, workers)
+	for i := range partials {
+		partials[i] = <-c
+		if partials[i].err != nil && firstErr == nil {
+			firstErr = partials[i].err
+			cancel()
+		}
+	}
+	endCount()
+
+	endMerge := options.stats.phase("merge")
+	total := partials[0].aggregators
+	for _, partial := range partials[1:] {
+		mergeAggregators(total, partial.aggregators)
+	}
+	endMerge()
+	return total, firstErr
+}
+
+// contextReader stops reading once ctx is done, returning its error.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+type partialAggregators struct {
+	aggregators []aggregator
+	err         error
+}
+
+func partialCountRecipeDelivery(ctx context.Context, recipeDeliveryPart []recipeDelivery, options recipeCountOptions, c chan<- partialAggregators) {
+	defer func() {
+		if r := recover(); r != nil {
+			c <- partialAggregators{newAggregators(options), fmt.Errorf("counting worker failed: %v", r)}
+		}
+	}()
+
+	aggregators := newAggregators(options)
+	err := aggregate(ctx, recipeDeliveryPart, options, aggregators)
+	c <- partialAggregators{aggregators, err}
+}
+
+// blockBounds returns the bounds of the i-th block, where the last block also takes the remainder records.
+func blockBounds(i int, blocksize int, blocks int, total int) (int, int) {
+	if i == blocks-1 {
+		return i * blocksize, total
+	}
+	return i * blocksize, (i + 1) * blocksize
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRecipeDelivery(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should decode records one at a time while reporting progress": func(t *testing.T) {
+			// given
+			stats := newPipelineStats()
+			stats.reportProgress(ioutil.Discard, time.Hour)()
+
+			// when
+			records, err := readRecipeDelivery(context.Background(), "../data/demo.json", false, stats)
+
+			// then
+			expected, _ := readRecipeDelivery(context.Background(), "../data/demo.json", false, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, records)
+			assert.Equal(t, int64(len(records)), stats.recordsDecoded)
+		},
+		"should not decode anything but an array of records": func(t *testing.T) {
+			// given
+			filePath := filepath.Join(t.TempDir(), "object.json")
+			ioutil.WriteFile(filePath, []byte(`{"postcode": "10120"}`), 0644)
+			stats := newPipelineStats()
+			stats.reportProgress(ioutil.Discard, time.Hour)()
+
+			// when
+			_, err := readRecipeDelivery(context.Background(), filePath, false, stats)
+			_, errNoStats := readRecipeDelivery(context.Background(), filePath, false, nil)
+
+			// then
+			assert.Error(t, err)
+			assert.Error(t, errNoStats)
+		},
+		"should stop reading when context is done": func(t *testing.T) {
+			// given
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			// when
+			_, err := readRecipeDelivery(ctx, "../data/demo.json", false, nil)
+
+			// then
+			assert.ErrorIs(t, err, context.Canceled)
+		},
+		"should stop decoding when context is done while decoding": func(t *testing.T) {
+			// given
+			records := make([]string, 100000)
+			for i := range records {
+				records[i] = `{"postcode": "10120", "recipe": "Creamy Dill Chicken", "delivery": "Wednesday 10AM - 3PM"}`
+			}
+			fileContent := []byte("[" + strings.Join(records, ",") + "]")
+			stats := newPipelineStats()
+			stats.reportProgress(ioutil.Discard, time.Hour)()
+
+			// when
+			decoded, err := decodeRecipeDelivery(&cancelAfterChecks{Context: context.Background(), checks: 1}, fileContent, false, nil)
+			decodedPerRecord, errPerRecord := decodeRecipeDelivery(&cancelAfterChecks{Context: context.Background(), checks: 2}, fileContent, false, stats)
+			decodedStoppable, errStoppable := decodeRecipeDelivery(&cancelAfterChecks{Context: context.Background(), checks: 2}, fileContent, true, nil)
+
+			// then
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.ErrorIs(t, errPerRecord, context.Canceled)
+			assert.ErrorIs(t, errStoppable, context.Canceled)
+			assert.Nil(t, decoded)
+			assert.Nil(t, decodedPerRecord)
+			assert.Nil(t, decodedStoppable)
+			assert.Equal(t, int64(cancelCheckInterval), stats.recordsDecoded)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+// cancelAfterChecks is a context that is done, but only reports it after its error was checked a number of times.
+type cancelAfterChecks struct {
+	context.Context
+	checks int
+}
+
+func (c *cancelAfterChecks) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (c *cancelAfterChecks) Err() error {
+	if c.checks > 0 {
+		c.checks--
+		return nil
+	}
+	return context.Canceled
+}
+
+func TestCountFile(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should count file": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("../data/demo.json", "", "", "")
+
+			// when
+			response, err := countFile(context.Background(), options)
+
+			// then
+			assert.NoError(t, err)
+			assert.False(t, response.Incomplete)
+			assert.True(t, response.CountPerPostcodeTime.Found)
+		},
+		"should count groups in both modes": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("../data/demo.json", "", "", "")
+			options.groupBy, _ = parseGroupByOptions("weekday", groupSortCount, 0)
+			approxOptions := options
+			approxOptions.approx, _ = parseApproxOptions(true, 10, 0.01, 0.01, 3)
+
+			// when
+			response, err := countFile(context.Background(), options)
+			approxResponse, approxErr := countFile(context.Background(), approxOptions)
+
+			// then
+			assert.NoError(t, err)
+			assert.NoError(t, approxErr)
+			assert.Equal(t, 5, response.CountPerGroup.GroupCount)
+			assert.Equal(t, response.CountPerGroup, approxResponse.CountPerGroup)
+		},
+		"should return partial counts flagged as incomplete when cancelled": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("../data/demo.json", "", "", "")
+			options.partial = true
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			// when
+			response, err := countFile(ctx, options)
+
+			// then
+			assert.NoError(t, err)
+			assert.True(t, response.Incomplete)
+		},
+		"should return error when cancelled without partial counts": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("../data/demo.json", "", "", "")
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			// when
+			_, err := countFile(ctx, options)
+
+			// then
+			assert.ErrorIs(t, err, context.Canceled)
+		},
+		"should return error when cancelled in approx mode": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("../data/demo.json", "", "", "")
+			options.approx, _ = parseApproxOptions(true, 10, 0.01, 0.01, 3)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			// when
+			_, err := countFile(ctx, options)
+
+			// then
+			assert.ErrorIs(t, err, context.Canceled)
+		},
+		"should report worker panics as failures": func(t *testing.T) {
+			// given
+			c := make(chan partialAggregators, 1)
+			input := []recipeDelivery{{Postcode: "10120", Recipe: "Jam", Delivery: "Monday 9AM - 5PM"}}
+			options := recipeCountOptions{where: &deliveryFilter{match: func(*deliveryRecord) bool { panic("bad filter") }}}
+
+			// when
+			partialCountRecipeDelivery(context.Background(), input, options, c)
+
+			// then
+			partial := <-c
+			assert.Error(t, partial.err)
+			assert.Len(t, partial.aggregators, 2)
+		},
+		"should stop the other workers on a worker failure": func(t *testing.T) {
+			// given
+			input := []recipeDelivery{{Postcode: "10120"}, {Postcode: "10186"}, {Postcode: "10208"}, {Postcode: "10101"}}
+			failure := errors.New("worker failed")
+			stopped := make(chan error, len(input))
+			worker := func(ctx context.Context, part []recipeDelivery, options recipeCountOptions, c chan<- partialAggregators) {
+				if part[0].Postcode == "10120" {
+					c <- partialAggregators{newAggregators(options), failure}
+					return
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
+				stopped <- ctx.Err()
+				c <- partialAggregators{newAggregators(options), ctx.Err()}
+			}
+
+			// when
+			total, err := countInParallel(context.Background(), input, recipeCountOptions{}, len(input), worker)
+
+			// then
+			assert.ErrorIs(t, err, failure)
+			assert.Len(t, total, 2)
+			for i := 1; i < len(input); i++ {
+				assert.ErrorIs(t, <-stopped, context.Canceled)
+			}
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestBlockBounds(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should give the remainder to the last block": func(t *testing.T) {
+			// when
+			first, firstEnd := blockBounds(0, 3, 3, 11)
+			last, lastEnd := blockBounds(2, 3, 3, 11)
+
+			// then
+			assert.Equal(t, []int{0, 3}, []int{first, firstEnd})
+			assert.Equal(t, []int{6, 11}, []int{last, lastEnd})
+		},
+		"should give every record to the last block when there are fewer records than blocks": func(t *testing.T) {
+			// when
+			start, end := blockBounds(7, 0, 8, 5)
+
+			// then
+			assert.Equal(t, []int{0, 5}, []int{start, end})
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// separates the postcode of a query from its delivery time, as in "10120@10AM-3PM"
+const querySeparator string = "@"
+
+// postcodeQuery searches the deliveries of a postcode within a delivery time, as -postcode and -time do.
+type postcodeQuery struct {
+	postcode    string
+	delivery    deliveryPeriod
+	searchStart int
+	searchEnd   int
+}
+
+// parsePostcodeQueries parses queries separated by commas, each a postcode followed by @ and its delivery time,
+// or by nothing to search within the default delivery time.
+func parsePostcodeQueries(queries string, delivery deliveryPeriod) ([]postcodeQuery, error) {
+	parsed := make([]postcodeQuery, 0)
+	for _, q := range strings.Split(queries, ",") {
+		if q = strings.TrimSpace(q); len(q) == 0 {
+			continue
+		}
+		query := postcodeQuery{postcode: q, delivery: delivery}
+		if i := strings.Index(q, querySeparator); i >= 0 {
+			period, err := parseDeliveryPeriod(q[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("query %q: %v", q, err)
+			}
+			query.postcode, query.delivery = strings.TrimSpace(q[:i]), period
+		}
+		if len(query.postcode) == 0 {
+			return nil, fmt.Errorf("query %q has no postcode", q)
+		}
+		query.searchStart, query.searchEnd = query.delivery.minutes()
+		parsed = append(parsed, query)
+	}
+	return parsed, nil
+}
+
+// queryAggregator counts the deliveries of every query, as count_per_postcode_and_time does for -postcode.
+type queryAggregator struct {
+	queries []postcodeQuery
+	// indexes of the queries of every queried postcode
+	byPostcode map[string][]int
+	counts     []postcodeMatches
+}
+
+func newQueryAggregator(options recipeCountOptions) aggregator {
+	byPostcode := make(map[string][]int)
+	for i, q := range options.queries {
+		byPostcode[q.postcode] = append(byPostcode[q.postcode], i)
+	}
+	return &queryAggregator{options.queries, byPostcode, make([]postcodeMatches, len(options.queries))}
+}
+
+func (a *queryAggregator) add(r *deliveryRecord) {
+	indexes, ok := a.byPostcode[r.Postcode]
+	if !ok {
+		return
+	}
+	window, ok := r.deliveryWindow()
+	for _, i := range indexes {
+		a.counts[i].deliveryCount++
+		if ok && window.includedIn(a.queries[i].searchStart, a.queries[i].searchEnd) {
+			a.counts[i].deliveryWithinTimeCount++
+		}
+	}
+}
+
+func (a *queryAggregator) merge(o aggregator) {
+	for i, matches := range o.(*queryAggregator).counts {
+		a.counts[i].deliveryCount += matches.deliveryCount
+		a.counts[i].deliveryWithinTimeCount += matches.deliveryWithinTimeCount
+	}
+}
+
+func (a *queryAggregator) result(response *recipeCountResponse) {
+	response.CountPerQuery = make([]postcodeTimeCount, len(a.queries))
+	for i, q := range a.queries {
+		response.CountPerQuery[i] = postcodeTimeCount{
+			Postcode:      q.postcode,
+			Found:         a.counts[i].deliveryCount > 0,
+			From:          q.delivery.start.Format(timestampLayout),
+			To:            q.delivery.end.Format(timestampLayout),
+			DeliveryCount: a.counts[i].deliveryWithinTimeCount,
+		}
+	}
+}
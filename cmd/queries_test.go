@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePostcodeQueries(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should parse queries with or without their own delivery time": func(t *testing.T) {
+			// given
+			delivery, _ := parseDeliveryPeriod("10AM-3PM")
+
+			// when
+			queries, err := parsePostcodeQueries("10120@9AM - 4PM, 10208,", delivery)
+
+			// then
+			assert.NoError(t, err)
+			assert.Len(t, queries, 2)
+			assert.Equal(t, "10120", queries[0].postcode)
+			assert.Equal(t, [2]int{9 * 60, 16 * 60}, [2]int{queries[0].searchStart, queries[0].searchEnd})
+			assert.Equal(t, "10208", queries[1].postcode)
+			assert.Equal(t, delivery, queries[1].delivery)
+		},
+		"should not parse invalid queries": func(t *testing.T) {
+			// when
+			_, errTime := parsePostcodeQueries("10120@noon", deliveryPeriod{})
+			_, errPostcode := parsePostcodeQueries("@9AM-4PM", deliveryPeriod{})
+
+			// then
+			assert.Error(t, errTime)
+			assert.Error(t, errPostcode)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestQueryAggregator(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Monday 9AM - 4PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 2PM - 4PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Someday"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should count every query in the order given": func(t *testing.T) {
+			// given
+			options, _ := parseSearchOptions("demo.json", "10120", "10AM-3PM", "")
+			options.queries, _ = parsePostcodeQueries("10120@9AM-4PM,10208,99999", options.delivery)
+			aggregators := newAggregators(options)
+
+			// when
+			err := aggregate(context.Background(), input, options, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, []postcodeTimeCount{
+				{Postcode: "10120", Found: true, From: "9AM", To: "4PM", DeliveryCount: 2},
+				{Postcode: "10208", Found: true, From: "10AM", To: "3PM", DeliveryCount: 0},
+				{Postcode: "99999", Found: false, From: "10AM", To: "3PM", DeliveryCount: 0},
+			}, response.CountPerQuery)
+			assert.Equal(t, 1, response.CountPerPostcodeTime.DeliveryCount)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
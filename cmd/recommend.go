@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+const windowLengthDefault time.Duration = 5 * time.Hour
+const windowAlternativesDefault int = 3
+
+// the latest a recommended window may end, as a window ending at 12AM would only include deliveries ending at 12AM
+const latestWindowEndHour int = 23
+
+type recommendOptions struct {
+	filePath     string
+	postcode     string
+	length       int
+	alternatives int
+}
+
+func parseRecommendOptions(filePath string, postcode string, length time.Duration, alternatives int) (recommendOptions, error) {
+	if len(filePath) == 0 {
+		return recommendOptions{}, errors.New("file is a required argument")
+	}
+	if len(postcode) == 0 {
+		postcode = postcodeDefault
+	}
+	if length%time.Hour != 0 || length < time.Hour || length > time.Duration(latestWindowEndHour)*time.Hour {
+		return recommendOptions{}, fmt.Errorf("window length must be a whole number of hours, from 1h to %dh", latestWindowEndHour)
+	}
+	if alternatives < 0 {
+		return recommendOptions{}, errors.New("alternatives must not be negative")
+	}
+	return recommendOptions{filePath, postcode, int(length.Hours()), alternatives}, nil
+}
+
+type windowRecommendation struct {
+	Postcode      string            `json:"postcode"`
+	Found         bool              `json:"found"`
+	LengthHours   int               `json:"length_hours"`
+	DeliveryCount int               `json:"delivery_count"`
+	Recommended   windowCandidate   `json:"recommended"`
+	Alternatives  []windowCandidate `json:"alternatives"`
+}
+
+// windowCandidate counts the deliveries a window includes, as -time counts them for count_per_postcode_and_time.
+type windowCandidate struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	DeliveryCount int    `json:"delivery_count"`
+}
+
+// recommendWindow scans every window of the given length starting on the hour, and recommends the one including
+// the most deliveries of the postcode, breaking ties by the earliest start. Deliveries are counted once per
+// distinct window of the day, so that every candidate only adds up those.
+func recommendWindow(recipeDeliveryInput []recipeDelivery, options recommendOptions) windowRecommendation {
+	perWindow := make(map[deliveryWindow]int)
+	recommendation := windowRecommendation{Postcode: options.postcode, LengthHours: options.length}
+	for _, r := range recipeDeliveryInput {
+		if r.Postcode != options.postcode {
+			continue
+		}
+		recommendation.Found = true
+		if w, ok := scanDeliveryWindow(r.Delivery); ok {
+			perWindow[deliveryWindow{w.start % minutesPerDay, w.end % minutesPerDay}]++
+			recommendation.DeliveryCount++
+		}
+	}
+
+	candidates := make([]windowCandidate, 0, latestWindowEndHour-options.length+1)
+	for start := 0; start+options.length <= latestWindowEndHour; start++ {
+		end := start + options.length
+		count := 0
+		for w, c := range perWindow {
+			if w.includedIn(start*60, end*60) {
+				count += c
+			}
+		}
+		candidates = append(candidates, windowCandidate{formatHour(start), formatHour(end), count})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].DeliveryCount > candidates[j].DeliveryCount
+	})
+
+	recommendation.Recommended = candidates[0]
+	alternatives := candidates[1:]
+	if len(alternatives) > options.alternatives {
+		alternatives = alternatives[:options.alternatives]
+	}
+	recommendation.Alternatives = alternatives
+	return recommendation
+}
+
+// runRecommendWindow writes the recommended delivery window of a postcode, exiting with exitNoData
+// when the postcode has no deliveries.
+func runRecommendWindow(args []string) int {
+	flags := flag.NewFlagSet("recommend-window", flag.ContinueOnError)
+	setUsage(flags, "recommend-window [options]", "Writes the delivery window of the given length including the most deliveries of a postcode, and the next best ones.")
+	filePath := flags.String("file", "", "fixtures data file path (required)")
+	postcode := flags.String("postcode", postcodeDefault, "postcode to recommend a delivery window for")
+	length := flags.Duration("length", windowLengthDefault, "length of the recommended delivery window, in whole hours")
+	alternatives := flags.Int("alternatives", windowAlternativesDefault, "number of next best windows reported")
+	if err := flags.Parse(args); err != nil {
+		return usageError(err)
+	}
+	options, err := parseRecommendOptions(*filePath, *postcode, *length, *alternatives)
+	if err != nil {
+		return usageError(err)
+	}
+
+	recipeDeliveryInput, err := readRecipeDelivery(context.Background(), options.filePath, false, nil)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	recommendation := recommendWindow(recipeDeliveryInput, options)
+
+	printer := json.NewEncoder(os.Stdout)
+	if err := printer.Encode(recommendation); err != nil {
+		log.Println(err)
+		return exitError
+	}
+	if !recommendation.Found {
+		return exitNoData
+	}
+	return exitOK
+}
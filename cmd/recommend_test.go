@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRecommendOptions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should parse options with the default postcode": func(t *testing.T) {
+			// when
+			options, err := parseRecommendOptions("demo.json", "", 4*time.Hour, 2)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, recommendOptions{"demo.json", postcodeDefault, 4, 2}, options)
+		},
+		"should not parse invalid options": func(t *testing.T) {
+			// when
+			_, errFile := parseRecommendOptions("", "10120", 4*time.Hour, 2)
+			_, errFraction := parseRecommendOptions("demo.json", "10120", 90*time.Minute, 2)
+			_, errShort := parseRecommendOptions("demo.json", "10120", 0, 2)
+			_, errLong := parseRecommendOptions("demo.json", "10120", 24*time.Hour, 2)
+			_, errAlternatives := parseRecommendOptions("demo.json", "10120", 4*time.Hour, -1)
+
+			// then
+			assert.Error(t, errFile)
+			assert.Error(t, errFraction)
+			assert.Error(t, errShort)
+			assert.Error(t, errLong)
+			assert.Error(t, errAlternatives)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestRecommendWindow(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 2PM"},
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Monday 11AM - 1PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Friday 1PM - 3PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Sunday 2PM - 3PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Someday"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Saturday 9AM - 1PM"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should recommend the window including the most deliveries, breaking ties by the earliest": func(t *testing.T) {
+			// given
+			options := recommendOptions{postcode: "10120", length: 4, alternatives: 2}
+
+			// when
+			recommendation := recommendWindow(input, options)
+
+			// then
+			assert.Equal(t, windowRecommendation{
+				Postcode:      "10120",
+				Found:         true,
+				LengthHours:   4,
+				DeliveryCount: 4,
+				Recommended:   windowCandidate{"11AM", "3PM", 3},
+				Alternatives: []windowCandidate{
+					{"10AM", "2PM", 2},
+					{"12PM", "4PM", 2},
+				},
+			}, recommendation)
+		},
+		"should match the count of the recommended window as a delivery time": func(t *testing.T) {
+			// given
+			options := recommendOptions{postcode: "10120", length: 4}
+			recommendation := recommendWindow(input, options)
+			countOptions, _ := parseSearchOptions("demo.json", "10120", recommendation.Recommended.From+"-"+recommendation.Recommended.To, "")
+
+			// when
+			aggregators := newAggregators(countOptions)
+			err := aggregate(context.Background(), input, countOptions, aggregators)
+			response := buildAggregatedResponse(aggregators)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, recommendation.Recommended.DeliveryCount, response.CountPerPostcodeTime.DeliveryCount)
+			assert.Empty(t, recommendation.Alternatives)
+		},
+		"should not find an absent postcode": func(t *testing.T) {
+			// when
+			recommendation := recommendWindow(input, recommendOptions{postcode: "99999", length: 23, alternatives: 3})
+
+			// then
+			assert.False(t, recommendation.Found)
+			assert.Equal(t, windowCandidate{"12AM", "11PM", 0}, recommendation.Recommended)
+			assert.Empty(t, recommendation.Alternatives)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// marks a regions file postcode as a prefix, as in "101*"
+const postcodePrefixWildcard string = "*"
+
+// postcodeRegion locates a postcode, or every postcode starting with a prefix.
+type postcodeRegion struct {
+	Postcode string `json:"postcode"`
+	City     string `json:"city"`
+	Region   string `json:"region"`
+	Depot    string `json:"depot"`
+}
+
+// postcodeRegions looks postcodes up by exact match first, then by their longest matching prefix.
+type postcodeRegions struct {
+	exact         map[string]postcodeRegion
+	prefixes      map[string]postcodeRegion
+	prefixLengths []int
+}
+
+func newPostcodeRegions(regions []postcodeRegion) (*postcodeRegions, error) {
+	r := &postcodeRegions{make(map[string]postcodeRegion), make(map[string]postcodeRegion), nil}
+	lengths := make(map[int]bool)
+	for i, region := range regions {
+		postcode := strings.TrimSpace(region.Postcode)
+		set, prefix := r.exact, false
+		if strings.HasSuffix(postcode, postcodePrefixWildcard) {
+			postcode = strings.TrimSuffix(postcode, postcodePrefixWildcard)
+			set, prefix = r.prefixes, true
+		}
+		if len(postcode) == 0 && !prefix {
+			return nil, fmt.Errorf("region %d has no postcode", i+1)
+		}
+		if _, exists := set[postcode]; exists {
+			return nil, fmt.Errorf("region postcode %q is repeated", region.Postcode)
+		}
+		set[postcode] = region
+		if prefix && !lengths[len(postcode)] {
+			lengths[len(postcode)] = true
+			r.prefixLengths = append(r.prefixLengths, len(postcode))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(r.prefixLengths)))
+	return r, nil
+}
+
+func (r *postcodeRegions) lookup(postcode string) (postcodeRegion, bool) {
+	if region, ok := r.exact[postcode]; ok {
+		return region, true
+	}
+	for _, l := range r.prefixLengths {
+		if l > len(postcode) {
+			continue
+		}
+		if region, ok := r.prefixes[postcode[:l]]; ok {
+			return region, true
+		}
+	}
+	return postcodeRegion{}, false
+}
+
+// loadPostcodeRegions reads a CSV regions file when it has a .csv extension, or a JSON one otherwise.
+// CSV files have a postcode,city,region,depot header.
+func loadPostcodeRegions(filePath string) (*postcodeRegions, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []postcodeRegion
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		regions, err = decodeRegionsCSV(bytes.NewReader(content))
+	} else {
+		err = json.Unmarshal(content, &regions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("regions %s: %v", filePath, err)
+	}
+	return newPostcodeRegions(regions)
+}
+
+func decodeRegionsCSV(r io.Reader) ([]postcodeRegion, error) {
+	regions := make([]postcodeRegion, 0)
+	err := decodeCSVRows(r, "postcode", func(field func(column string) string) {
+		regions = append(regions, postcodeRegion{
+			Postcode: field("postcode"),
+			City:     field("city"),
+			Region:   field("region"),
+			Depot:    field("depot"),
+		})
+	})
+	return regions, err
+}
+
+type regionReport struct {
+	CountPerCity          []regionCount `json:"count_per_city"`
+	CountPerRegion        []regionCount `json:"count_per_region"`
+	CountPerDepot         []regionCount `json:"count_per_depot"`
+	BusiestCity           regionCount   `json:"busiest_city"`
+	BusiestRegion         regionCount   `json:"busiest_region"`
+	BusiestDepot          regionCount   `json:"busiest_depot"`
+	UnmappedPostcodeCount int           `json:"unmapped_postcode_count"`
+	UnmappedDeliveryCount int           `json:"unmapped_delivery_count"`
+}
+
+type regionCount struct {
+	Name            string        `json:"name"`
+	PostcodeCount   int           `json:"postcode_count"`
+	DeliveryCount   int           `json:"delivery_count"`
+	BusiestPostcode postcodeCount `json:"busiest_postcode"`
+}
+
+// regionCountSet rolls postcode counts up into one of the city, region or depot levels.
+type regionCountSet map[string]regionCount
+
+func (s regionCountSet) add(name string, postcode string, deliveryCount int) {
+	count := s[name]
+	count.Name = name
+	count.PostcodeCount++
+	count.DeliveryCount += deliveryCount
+	busiest := count.BusiestPostcode
+	if deliveryCount > busiest.DeliveryCount || deliveryCount == busiest.DeliveryCount && postcode < busiest.Postcode {
+		count.BusiestPostcode = postcodeCount{Postcode: postcode, Found: true, DeliveryCount: deliveryCount}
+	}
+	s[name] = count
+}
+
+// toSortedList sorts by name, returning the busiest entry too, with ties broken by name.
+func (s regionCountSet) toSortedList() ([]regionCount, regionCount) {
+	list := make([]regionCount, 0, len(s))
+	for _, c := range s {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	var busiest regionCount
+	for _, c := range list {
+		if c.DeliveryCount > busiest.DeliveryCount {
+			busiest = c
+		}
+	}
+	return list, busiest
+}
+
+// rollupRegions adds the per postcode counts up per city, region and depot, leaving unnamed levels out.
+func (s postcodeCountSet) rollupRegions(regions *postcodeRegions) *regionReport {
+	cities, regionSet, depots := make(regionCountSet), make(regionCountSet), make(regionCountSet)
+	report := &regionReport{}
+	for postcode, matches := range s {
+		region, ok := regions.lookup(postcode)
+		if !ok {
+			report.UnmappedPostcodeCount++
+			report.UnmappedDeliveryCount += matches.deliveryCount
+			continue
+		}
+		if len(region.City) > 0 {
+			cities.add(region.City, postcode, matches.deliveryCount)
+		}
+		if len(region.Region) > 0 {
+			regionSet.add(region.Region, postcode, matches.deliveryCount)
+		}
+		if len(region.Depot) > 0 {
+			depots.add(region.Depot, postcode, matches.deliveryCount)
+		}
+	}
+
+	report.CountPerCity, report.BusiestCity = cities.toSortedList()
+	report.CountPerRegion, report.BusiestRegion = regionSet.toSortedList()
+	report.CountPerDepot, report.BusiestDepot = depots.toSortedList()
+	return report
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPostcodeRegions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should look postcodes up by exact match, then by longest prefix": func(t *testing.T) {
+			// when
+			regions, err := loadPostcodeRegions("../data/regions.csv")
+
+			// then
+			assert.NoError(t, err)
+			exact, _ := regions.lookup("10120")
+			longest, _ := regions.lookup("10137")
+			shortest, _ := regions.lookup("10121")
+			_, found := regions.lookup("99999")
+			assert.Equal(t, "Springfield Central", exact.Depot)
+			assert.Equal(t, "Shelbyville", longest.Depot)
+			assert.Equal(t, "Springfield East", shortest.Depot)
+			assert.False(t, found)
+		},
+		"should load JSON regions": func(t *testing.T) {
+			// given
+			filePath := writeConfigFile(t, "regions.json", `[{"postcode": "*", "region": "Everywhere"}]`)
+
+			// when
+			regions, err := loadPostcodeRegions(filePath)
+
+			// then
+			assert.NoError(t, err)
+			region, found := regions.lookup("10120")
+			assert.True(t, found)
+			assert.Equal(t, "Everywhere", region.Region)
+		},
+		"should not load invalid regions": func(t *testing.T) {
+			// given
+			noPostcode := writeConfigFile(t, "no-postcode.csv", "city,region\nSpringfield,North\n")
+			emptyPostcode := writeConfigFile(t, "empty-postcode.json", `[{"postcode": "", "region": "North"}]`)
+			repeated := writeConfigFile(t, "repeated.csv", "postcode,region\n101*,North\n101*,South\n")
+
+			// when
+			_, errMissing := loadPostcodeRegions("../data/missing.csv")
+			_, errNoPostcode := loadPostcodeRegions(noPostcode)
+			_, errEmptyPostcode := loadPostcodeRegions(emptyPostcode)
+			_, errRepeated := loadPostcodeRegions(repeated)
+
+			// then
+			assert.Error(t, errMissing)
+			assert.Error(t, errNoPostcode)
+			assert.Error(t, errEmptyPostcode)
+			assert.Error(t, errRepeated)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestRollupRegions(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should roll postcode counts up per city, region and depot": func(t *testing.T) {
+			// given
+			regions, _ := newPostcodeRegions([]postcodeRegion{
+				{Postcode: "10120", City: "Springfield", Region: "North", Depot: "Central"},
+				{Postcode: "101*", City: "Springfield", Region: "North", Depot: "East"},
+				{Postcode: "102*", City: "Capital City", Region: "South"},
+			})
+			set := make(postcodeCountSet)
+			for _, p := range []string{"10120", "10120", "10130", "10131", "10131", "10208", "99999"} {
+				set.add(p, false)
+			}
+
+			// when
+			report := set.rollupRegions(regions)
+
+			// then
+			assert.Equal(t, []regionCount{
+				{Name: "North", PostcodeCount: 3, DeliveryCount: 5, BusiestPostcode: postcodeCount{Postcode: "10120", Found: true, DeliveryCount: 2}},
+				{Name: "South", PostcodeCount: 1, DeliveryCount: 1, BusiestPostcode: postcodeCount{Postcode: "10208", Found: true, DeliveryCount: 1}},
+			}, report.CountPerRegion)
+			assert.Equal(t, []regionCount{
+				{Name: "Central", PostcodeCount: 1, DeliveryCount: 2, BusiestPostcode: postcodeCount{Postcode: "10120", Found: true, DeliveryCount: 2}},
+				{Name: "East", PostcodeCount: 2, DeliveryCount: 3, BusiestPostcode: postcodeCount{Postcode: "10131", Found: true, DeliveryCount: 2}},
+			}, report.CountPerDepot)
+			assert.Equal(t, "Springfield", report.BusiestCity.Name)
+			assert.Equal(t, "North", report.BusiestRegion.Name)
+			assert.Equal(t, "East", report.BusiestDepot.Name)
+			assert.Equal(t, 1, report.UnmappedPostcodeCount)
+			assert.Equal(t, 1, report.UnmappedDeliveryCount)
+		},
+		"should break ties by name": func(t *testing.T) {
+			// given
+			regions, _ := newPostcodeRegions([]postcodeRegion{{Postcode: "1*", Region: "North"}, {Postcode: "2*", Region: "East"}})
+			set := postcodeCountSet{"10120": {deliveryCount: 1}, "10121": {deliveryCount: 1}, "20120": {deliveryCount: 2}}
+
+			// when
+			report := set.rollupRegions(regions)
+
+			// then
+			assert.Equal(t, "East", report.BusiestRegion.Name)
+			assert.Equal(t, "10120", report.CountPerRegion[1].BusiestPostcode.Postcode)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
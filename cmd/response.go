@@ -2,7 +2,6 @@ package main
 
 import (
 	"sort"
-	"strings"
 )
 
 type recipeDelivery struct {
@@ -12,32 +11,63 @@ type recipeDelivery struct {
 }
 
 type recipeCountResponse struct {
-	UniqueRecipeCount    int               `json:"unique_recipe_count"`
-	CountPerRecipe       recipeCountList   `json:"count_per_recipe"`
-	BusiestPostcode      postcodeCount     `json:"busiest_postcode"`
-	CountPerPostcodeTime postcodeTimeCount `json:"count_per_postcode_and_time"`
-	MatchByName          []string          `json:"match_by_name"`
+	UniqueRecipeCount    int                 `json:"unique_recipe_count"`
+	CountPerRecipe       recipeCountList     `json:"count_per_recipe"`
+	BusiestPostcode      postcodeCount       `json:"busiest_postcode"`
+	CountPerPostcodeTime postcodeTimeCount   `json:"count_per_postcode_and_time"`
+	MatchByName          []string            `json:"match_by_name"`
+	CountPerQuery        []postcodeTimeCount `json:"count_per_query,omitempty"`
+	CountPerGroup        *groupCountReport   `json:"count_per_group,omitempty"`
+	Catalog              *catalogReport      `json:"catalog,omitempty"`
+	Regions              *regionReport       `json:"regions,omitempty"`
+	Distribution         *distributionReport `json:"distribution,omitempty"`
+	Compliance           *complianceReport   `json:"compliance,omitempty"`
+	PeakPerPostcode      []postcodePeak      `json:"peak_per_postcode,omitempty"`
+	WindowWidths         *windowWidthReport  `json:"window_widths,omitempty"`
+	Capacity             *capacityReport     `json:"capacity,omitempty"`
+	Busiest              *busiestReport      `json:"busiest,omitempty"`
+	Approximation        *approximation      `json:"approximation,omitempty"`
+	Incomplete           bool                `json:"incomplete,omitempty"`
 }
 
 type recipeCountList []recipeCount
 
-func (l recipeCountList) filterByNames(names ...string) []string {
+// filterByNames returns the recipes matching the search, in the order of the list.
+func (l recipeCountList) filterByNames(search nameSearch) []string {
 	list := make([]string, 0)
 
 	for _, r := range l {
-		recipeName := strings.ToLower(r.Recipe)
-		for _, n := range names {
-			searchName := strings.ToLower(n)
-			if strings.Contains(recipeName, searchName) {
-				list = append(list, r.Recipe)
-				break
-			}
+		if search.matches(r.Recipe) {
+			list = append(list, r.Recipe)
 		}
 	}
-	sort.Strings(list)
 	return list
 }
 
+// order sorts a list sorted by name as given by options, breaking ties in counts by name,
+// and returns the page of the list options ask for.
+func (l recipeCountList) order(options recipeOrderOptions) recipeCountList {
+	switch options.sort {
+	case recipeSortCount:
+		sort.SliceStable(l, func(i, j int) bool {
+			return l[i].DeliveryCount > l[j].DeliveryCount
+		})
+	case recipeSortCountAsc:
+		sort.SliceStable(l, func(i, j int) bool {
+			return l[i].DeliveryCount < l[j].DeliveryCount
+		})
+	}
+
+	if options.offset >= len(l) {
+		return make(recipeCountList, 0)
+	}
+	l = l[options.offset:]
+	if options.limit > 0 && len(l) > options.limit {
+		l = l[:options.limit]
+	}
+	return l
+}
+
 type recipeCount struct {
 	Recipe        string `json:"recipe"`
 	DeliveryCount int    `json:"count"`
@@ -45,33 +75,23 @@ type recipeCount struct {
 
 type postcodeCount struct {
 	Postcode      string `json:"postcode"`
+	Found         bool   `json:"found"`
 	DeliveryCount int    `json:"delivery_count"`
 }
 
 type postcodeTimeCount struct {
 	Postcode      string `json:"postcode"`
+	Found         bool   `json:"found"`
 	From          string `json:"from"`
 	To            string `json:"to"`
 	DeliveryCount int    `json:"delivery_count"`
 }
 
-func buildCountResponse(recipeCountSet recipeCountSet, postcodeCountSet postcodeCountSet, options recipeCountOptions) recipeCountResponse {
-	sortedRecipeList := recipeCountSet.toSortedList()
-	busiestPostcode := postcodeCountSet.findBusiestPostcode()
-
-	return recipeCountResponse{
-		UniqueRecipeCount: len(sortedRecipeList),
-		CountPerRecipe:    sortedRecipeList,
-		BusiestPostcode: postcodeCount{
-			Postcode:      busiestPostcode,
-			DeliveryCount: postcodeCountSet[busiestPostcode].deliveryCount,
-		},
-		CountPerPostcodeTime: postcodeTimeCount{
-			Postcode:      options.postcode,
-			From:          options.delivery.start.Format(timestampLayout),
-			To:            options.delivery.end.Format(timestampLayout),
-			DeliveryCount: postcodeCountSet[options.postcode].deliveryWithinTimeCount,
-		},
-		MatchByName: sortedRecipeList.filterByNames(options.recipes.names()...),
-	}
+// approximation describes the error bounds of a response computed in approx mode,
+// where count_per_recipe only holds the top recipes and every count is an estimate.
+type approximation struct {
+	UniquePostcodeCount   int     `json:"unique_postcode_count"`
+	UniqueCountRelError   float64 `json:"unique_count_relative_error"`
+	DeliveryCountAbsError int     `json:"delivery_count_absolute_error"`
+	Confidence            float64 `json:"confidence"`
 }
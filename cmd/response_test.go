@@ -20,11 +20,46 @@ func TestRecipesCountList(t *testing.T) {
 			)
 
 			// when
-			recipes := recipeCountList.filterByNames("Coffee", "tangerine")
+			recipes := recipeCountList.filterByNames(newNameSearch([]string{"Coffee", "tangerine"}, false))
 
 			// then
 			assert.ElementsMatch(t, [...]string{"Starfish and coffee", "Tangerine"}, recipes)
 		},
+		"should order by count, breaking ties by name, and keep the page asked for": func(t *testing.T) {
+			// given
+			set := recipeCountSet{"Tangerine": 3, "Butterscotch clouds": 1, "Maple syrup and jam": 3, "Side order of ham": 2, "Starfish and coffee": 1}
+			leaders, _ := parseRecipeOrderOptions(recipeSortCount, 2, 0)
+			longTail, _ := parseRecipeOrderOptions(recipeSortCountAsc, 3, 0)
+			page, _ := parseRecipeOrderOptions(recipeSortName, 2, 1)
+			past, _ := parseRecipeOrderOptions(recipeSortCount, 0, 5)
+
+			// then
+			assert.Equal(t, recipeCountList{
+				{Recipe: "Maple syrup and jam", DeliveryCount: 3},
+				{Recipe: "Tangerine", DeliveryCount: 3},
+			}, set.toSortedList().order(leaders))
+			assert.Equal(t, recipeCountList{
+				{Recipe: "Butterscotch clouds", DeliveryCount: 1},
+				{Recipe: "Starfish and coffee", DeliveryCount: 1},
+				{Recipe: "Side order of ham", DeliveryCount: 2},
+			}, set.toSortedList().order(longTail))
+			assert.Equal(t, recipeCountList{
+				{Recipe: "Maple syrup and jam", DeliveryCount: 3},
+				{Recipe: "Side order of ham", DeliveryCount: 2},
+			}, set.toSortedList().order(page))
+			assert.Equal(t, recipeCountList{}, set.toSortedList().order(past))
+		},
+		"should not parse invalid order options": func(t *testing.T) {
+			// when
+			_, errSort := parseRecipeOrderOptions("random", 0, 0)
+			_, errLimit := parseRecipeOrderOptions(recipeSortCount, -1, 0)
+			_, errOffset := parseRecipeOrderOptions(recipeSortCount, 0, -1)
+
+			// then
+			assert.Error(t, errSort)
+			assert.Error(t, errLimit)
+			assert.Error(t, errOffset)
+		},
 	}
 
 	for name, run := range tests {
@@ -34,7 +69,14 @@ func TestRecipesCountList(t *testing.T) {
 	}
 }
 
-//buildResponse(recipeCountSet recipeCountSet, postcodeCountSet postcodeCountSet, options recipeCountOptions)
+// buildSetsResponse builds the response of the recipe and postcode aggregators holding the given counts.
+func buildSetsResponse(recipeSet recipeCountSet, postcodeSet postcodeCountSet, options recipeCountOptions) recipeCountResponse {
+	aggregators := newAggregators(options)
+	aggregators[0].(*recipeAggregator).set = recipeSet
+	aggregators[1].(*postcodeAggregator).set = postcodeSet
+	return buildAggregatedResponse(aggregators)
+}
+
 func TestBuildResponse(t *testing.T) {
 	tests := map[string]func(*testing.T){
 		"should build a response": func(t *testing.T) {
@@ -65,7 +107,7 @@ func TestBuildResponse(t *testing.T) {
 			}
 
 			// when
-			response := buildCountResponse(recipeSet, postcodeSet, options)
+			response := buildSetsResponse(recipeSet, postcodeSet, options)
 
 			// then
 			expectedCountPerRecipe := make(recipeCountList, 0)
@@ -83,10 +125,12 @@ func TestBuildResponse(t *testing.T) {
 				CountPerRecipe:    expectedCountPerRecipe,
 				BusiestPostcode: postcodeCount{
 					Postcode:      "30000",
+					Found:         true,
 					DeliveryCount: 3,
 				},
 				CountPerPostcodeTime: postcodeTimeCount{
 					Postcode:      "20000",
+					Found:         true,
 					From:          options.delivery.start.Format(timestampLayout),
 					To:            options.delivery.end.Format(timestampLayout),
 					DeliveryCount: 1,
@@ -96,6 +140,25 @@ func TestBuildResponse(t *testing.T) {
 
 			assert.Equal(t, expected, response)
 		},
+		"should build a response for an absent postcode and empty sets": func(t *testing.T) {
+			// given
+			deliveryWindow, _ := parseDeliveryPeriod("10AM-3PM")
+			options := recipeCountOptions{
+				postcode: "99999",
+				delivery: deliveryWindow,
+				recipes:  make(recipeSearchSet),
+			}
+
+			// when
+			response := buildSetsResponse(make(recipeCountSet), make(postcodeCountSet), options)
+
+			// then
+			assert.Equal(t, 0, response.UniqueRecipeCount)
+			assert.Equal(t, postcodeCount{}, response.BusiestPostcode)
+			assert.Equal(t, "99999", response.CountPerPostcodeTime.Postcode)
+			assert.False(t, response.CountPerPostcodeTime.Found)
+			assert.Equal(t, 0, response.CountPerPostcodeTime.DeliveryCount)
+		},
 	}
 
 	for name, run := range tests {
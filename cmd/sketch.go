@@ -0,0 +1,261 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// hashString hashes s with FNV-1a and then runs the result through the murmur3 finalizer,
+// as FNV alone does not spread short, similar keys such as postcodes well enough for the sketches.
+func hashString(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// hyperLogLog estimates the number of distinct keys using 2^precision registers,
+// with a standard error of 1.04/sqrt(2^precision).
+type hyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+func newHyperLogLog(precision uint8) *hyperLogLog {
+	return &hyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}
+}
+
+func (h *hyperLogLog) add(key string) {
+	hash := hashString(key)
+	index := hash >> (64 - h.precision)
+	rank := uint8(bits.LeadingZeros64(hash<<h.precision|1<<(h.precision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+func (h *hyperLogLog) merge(o *hyperLogLog) {
+	for i, r := range o.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+func (h *hyperLogLog) estimate() int {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// small range correction, linear counting is more accurate here
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+func (h *hyperLogLog) relativeError() float64 {
+	return 1.04 / math.Sqrt(float64(len(h.registers)))
+}
+
+// countMinSketch estimates per key counts, never underestimating them. With width ceil(e/epsilon)
+// and depth ceil(ln(1/delta)) the overestimation is at most epsilon*total with probability 1-delta.
+type countMinSketch struct {
+	width int
+	depth int
+	total int
+	cells []int
+}
+
+func newCountMinSketch(epsilon float64, delta float64) *countMinSketch {
+	width := int(math.Ceil(math.E / epsilon))
+	depth := int(math.Ceil(math.Log(1 / delta)))
+	return &countMinSketch{
+		width: width,
+		depth: depth,
+		cells: make([]int, width*depth),
+	}
+}
+
+// cell returns the position of key on the given row, using double hashing to derive one hash per row.
+func (s *countMinSketch) cell(hash uint64, row int) int {
+	h1, h2 := uint32(hash), uint32(hash>>32)|1
+	return row*s.width + int((h1+uint32(row)*h2)%uint32(s.width))
+}
+
+func (s *countMinSketch) add(key string, count int) int {
+	hash := hashString(key)
+	estimate := math.MaxInt64
+	for row := 0; row < s.depth; row++ {
+		c := s.cell(hash, row)
+		s.cells[c] += count
+		if s.cells[c] < estimate {
+			estimate = s.cells[c]
+		}
+	}
+	s.total += count
+	return estimate
+}
+
+func (s *countMinSketch) estimate(key string) int {
+	hash := hashString(key)
+	estimate := math.MaxInt64
+	for row := 0; row < s.depth; row++ {
+		if v := s.cells[s.cell(hash, row)]; v < estimate {
+			estimate = v
+		}
+	}
+	return estimate
+}
+
+// merge requires both sketches to be built with the same epsilon and delta.
+func (s *countMinSketch) merge(o *countMinSketch) {
+	for i, v := range o.cells {
+		s.cells[i] += v
+	}
+	s.total += o.total
+}
+
+func (s *countMinSketch) absoluteError() int {
+	return int(math.Ceil(math.E / float64(s.width) * float64(s.total)))
+}
+
+// heavyHitters tracks the size keys with the highest estimated counts on top of a count-min sketch.
+// The tracked keys are kept in a min-heap by estimate, so that a key is checked against the lowest one
+// in constant time, and replaces it in logarithmic time.
+type heavyHitters struct {
+	size   int
+	sketch *countMinSketch
+	top    map[string]*hitter
+	heap   hitterHeap
+}
+
+type hitter struct {
+	key      string
+	estimate int
+	index    int
+}
+
+// hitterHeap orders hitters by lowest estimate first, breaking ties by the highest key first,
+// so that the same key is evicted whichever order keys were offered in.
+type hitterHeap []*hitter
+
+func (h hitterHeap) Len() int {
+	return len(h)
+}
+
+func (h hitterHeap) Less(i, j int) bool {
+	if h[i].estimate != h[j].estimate {
+		return h[i].estimate < h[j].estimate
+	}
+	return h[i].key > h[j].key
+}
+
+func (h hitterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *hitterHeap) Push(x interface{}) {
+	e := x.(*hitter)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *hitterHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+func newHeavyHitters(size int, epsilon float64, delta float64) *heavyHitters {
+	return &heavyHitters{
+		size:   size,
+		sketch: newCountMinSketch(epsilon, delta),
+		top:    make(map[string]*hitter, size),
+		heap:   make(hitterHeap, 0, size),
+	}
+}
+
+func (h *heavyHitters) add(key string) {
+	h.offer(key, h.sketch.add(key, 1))
+}
+
+func (h *heavyHitters) offer(key string, estimate int) {
+	if e, tracked := h.top[key]; tracked {
+		e.estimate = estimate
+		heap.Fix(&h.heap, e.index)
+		return
+	}
+	if len(h.heap) < h.size {
+		e := &hitter{key: key, estimate: estimate}
+		h.top[key] = e
+		heap.Push(&h.heap, e)
+		return
+	}
+
+	lowest := h.heap[0]
+	if estimate > lowest.estimate {
+		delete(h.top, lowest.key)
+		lowest.key, lowest.estimate = key, estimate
+		h.top[key] = lowest
+		heap.Fix(&h.heap, 0)
+	}
+}
+
+// merge combines the sketches first, so every candidate from either side is re-estimated against the merged counts.
+func (h *heavyHitters) merge(o *heavyHitters) {
+	h.sketch.merge(o.sketch)
+
+	candidates := make([]string, 0, len(h.top)+len(o.top))
+	for k := range h.top {
+		candidates = append(candidates, k)
+	}
+	for k := range o.top {
+		candidates = append(candidates, k)
+	}
+
+	h.top = make(map[string]*hitter, h.size)
+	h.heap = make(hitterHeap, 0, h.size)
+	for _, k := range candidates {
+		h.offer(k, h.sketch.estimate(k))
+	}
+}
+
+func (h *heavyHitters) toSortedList() recipeCountList {
+	list := make(recipeCountList, 0, len(h.top))
+	for k, e := range h.top {
+		list = append(list, recipeCount{
+			Recipe:        k,
+			DeliveryCount: e.estimate,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DeliveryCount != list[j].DeliveryCount {
+			return list[i].DeliveryCount > list[j].DeliveryCount
+		}
+		return list[i].Recipe < list[j].Recipe
+	})
+	return list
+}
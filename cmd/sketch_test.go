@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should estimate distinct keys within error": func(t *testing.T) {
+			// given
+			hll := newHyperLogLog(14)
+
+			// when
+			for i := 0; i < 100000; i++ {
+				hll.add(fmt.Sprintf("%d", i%50000))
+			}
+
+			// then
+			assert.InEpsilon(t, 50000, hll.estimate(), 3*hll.relativeError())
+		},
+		"should estimate small cardinalities exactly": func(t *testing.T) {
+			// given
+			hll := newHyperLogLog(14)
+
+			// when
+			hll.add("10120")
+			hll.add("10208")
+			hll.add("10120")
+
+			// then
+			assert.Equal(t, 2, hll.estimate())
+		},
+		"should merge into the union estimate": func(t *testing.T) {
+			// given
+			hll := newHyperLogLog(12)
+			hllOther := newHyperLogLog(12)
+			hllUnion := newHyperLogLog(12)
+			for i := 0; i < 20000; i++ {
+				hll.add(fmt.Sprintf("a%d", i))
+				hllUnion.add(fmt.Sprintf("a%d", i))
+				hllOther.add(fmt.Sprintf("b%d", i))
+				hllUnion.add(fmt.Sprintf("b%d", i))
+			}
+
+			// when
+			hll.merge(hllOther)
+
+			// then
+			assert.Equal(t, hllUnion.estimate(), hll.estimate())
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should never underestimate and stay within error": func(t *testing.T) {
+			// given
+			sketch := newCountMinSketch(0.001, 0.01)
+			exact := make(map[string]int)
+
+			// when
+			for i := 0; i < 50000; i++ {
+				key := fmt.Sprintf("%d", i*i%7919)
+				sketch.add(key, 1)
+				exact[key]++
+			}
+
+			// then
+			for k, v := range exact {
+				estimate := sketch.estimate(k)
+				assert.GreaterOrEqual(t, estimate, v)
+				assert.LessOrEqual(t, estimate, v+sketch.absoluteError())
+			}
+		},
+		"should merge sketches": func(t *testing.T) {
+			// given
+			sketch := newCountMinSketch(0.01, 0.01)
+			sketch.add("Maple", 3)
+			setOther := newCountMinSketch(0.01, 0.01)
+			setOther.add("Maple", 2)
+			setOther.add("Jam", 1)
+
+			// when
+			sketch.merge(setOther)
+
+			// then
+			assert.Equal(t, 5, sketch.estimate("Maple"))
+			assert.Equal(t, 1, sketch.estimate("Jam"))
+			assert.Equal(t, 6, sketch.total)
+		},
+		"should size the sketch from the error bounds": func(t *testing.T) {
+			// when
+			sketch := newCountMinSketch(0.01, 0.01)
+
+			// then
+			assert.Equal(t, int(math.Ceil(math.E/0.01)), sketch.width)
+			assert.Equal(t, 5, sketch.depth)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestHeavyHitters(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should keep the most frequent keys": func(t *testing.T) {
+			// given
+			hitters := newHeavyHitters(2, 0.001, 0.01)
+
+			// when
+			for i := 0; i < 1000; i++ {
+				hitters.add(fmt.Sprintf("rare%d", i))
+				if i%2 == 0 {
+					hitters.add("Maple")
+				}
+				if i%4 == 0 {
+					hitters.add("Syrup")
+				}
+			}
+
+			// then
+			list := hitters.toSortedList()
+			assert.Equal(t, 2, len(list))
+			assert.Equal(t, "Maple", list[0].Recipe)
+			assert.Equal(t, "Syrup", list[1].Recipe)
+		},
+		"should evict the lowest estimate, ties going to the highest key": func(t *testing.T) {
+			// given
+			hitters := newHeavyHitters(2, 0.001, 0.01)
+
+			// when
+			hitters.add("Syrup")
+			hitters.add("Jam")
+			hitters.add("Maple")
+			hitters.add("Maple")
+
+			// then
+			expected := recipeCountList{
+				{Recipe: "Maple", DeliveryCount: 2},
+				{Recipe: "Jam", DeliveryCount: 1},
+			}
+			assert.Equal(t, expected, hitters.toSortedList())
+		},
+		"should merge candidates from both sides": func(t *testing.T) {
+			// given
+			hitters := newHeavyHitters(2, 0.01, 0.01)
+			hitters.add("Maple")
+			hitters.add("Maple")
+			hitters.add("Jam")
+			hittersOther := newHeavyHitters(2, 0.01, 0.01)
+			hittersOther.add("Syrup")
+			hittersOther.add("Syrup")
+			hittersOther.add("Syrup")
+			hittersOther.add("Maple")
+
+			// when
+			hitters.merge(hittersOther)
+
+			// then
+			expected := recipeCountList{
+				{Recipe: "Maple", DeliveryCount: 3},
+				{Recipe: "Syrup", DeliveryCount: 3},
+			}
+			assert.Equal(t, expected, hitters.toSortedList())
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
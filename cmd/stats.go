@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const progressIntervalDefault time.Duration = time.Second
+
+// pipelineStats tracks how far the counting pipeline got and how long each of its phases took.
+// Every method accepts a nil receiver, for runs without -progress nor -timings.
+type pipelineStats struct {
+	bytesRead      int64
+	recordsDecoded int64
+	recordsCounted int64
+	reporting      bool
+
+	mu      sync.Mutex
+	current string
+	started time.Time
+	phases  []phaseTiming
+}
+
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+func newPipelineStats() *pipelineStats {
+	return &pipelineStats{started: time.Now()}
+}
+
+// phase marks the start of the named phase, returning the function that marks its end.
+func (s *pipelineStats) phase(name string) func() {
+	if s == nil {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	s.current = name
+	s.mu.Unlock()
+	start := time.Now()
+	return func() {
+		s.mu.Lock()
+		s.phases = append(s.phases, phaseTiming{name, time.Since(start)})
+		s.mu.Unlock()
+	}
+}
+
+// decodesPerRecord reports whether records should be decoded one at a time, so that decoding progress can be
+// reported. It is slower than decoding all records at once, and therefore only done while reporting progress,
+// or when a timeout or the watcher can stop the run.
+func (s *pipelineStats) decodesPerRecord() bool {
+	return s != nil && s.reporting
+}
+
+func (s *pipelineStats) addBytesRead(n int) {
+	if s != nil {
+		atomic.AddInt64(&s.bytesRead, int64(n))
+	}
+}
+
+func (s *pipelineStats) addRecordsDecoded(n int) {
+	if s != nil {
+		atomic.AddInt64(&s.recordsDecoded, int64(n))
+	}
+}
+
+func (s *pipelineStats) addRecordsCounted(n int) {
+	if s != nil {
+		atomic.AddInt64(&s.recordsCounted, int64(n))
+	}
+}
+
+// reportProgress writes a progress line to w every interval, until the returned function is called.
+func (s *pipelineStats) reportProgress(w io.Writer, interval time.Duration) func() {
+	if s == nil {
+		return func() {}
+	}
+
+	s.reporting = true
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lastRecords := int64(0)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				current := s.current
+				s.mu.Unlock()
+				decoded := atomic.LoadInt64(&s.recordsDecoded)
+				counted := atomic.LoadInt64(&s.recordsCounted)
+				rate := float64(decoded+counted-lastRecords) / interval.Seconds()
+				lastRecords = decoded + counted
+				fmt.Fprintf(w, "progress: %s, %s read, %d records decoded, %d records counted, %.0f records/s\n",
+					current, formatBytes(atomic.LoadInt64(&s.bytesRead)), decoded, counted, rate)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// printTimings writes the duration of every finished phase to w, in the order they happened.
+func (s *pipelineStats) printTimings(w io.Writer) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timings := make([]string, 0, len(s.phases)+1)
+	for _, p := range s.phases {
+		timings = append(timings, fmt.Sprintf("%s %s", p.name, p.duration.Round(time.Microsecond)))
+	}
+	timings = append(timings, fmt.Sprintf("total %s", time.Since(s.started).Round(time.Microsecond)))
+	fmt.Fprintf(w, "timings: %s\n", strings.Join(timings, ", "))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// countingReader reports every read to the pipeline stats.
+type countingReader struct {
+	reader io.Reader
+	stats  *pipelineStats
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.stats.addBytesRead(n)
+	return n, err
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineStats(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should print timings of every phase": func(t *testing.T) {
+			// given
+			stats := newPipelineStats()
+			var output bytes.Buffer
+
+			// when
+			stats.phase("read")()
+			stats.phase("decode")()
+			stats.printTimings(&output)
+
+			// then
+			assert.Regexp(t, `^timings: read .+, decode .+, total .+\n$`, output.String())
+		},
+		"should report progress until stopped": func(t *testing.T) {
+			// given
+			stats := newPipelineStats()
+			stats.phase("count")
+			stats.addBytesRead(2048)
+			stats.addRecordsDecoded(10)
+			stats.addRecordsCounted(4)
+			var output bytes.Buffer
+
+			// when
+			stop := stats.reportProgress(&output, 5*time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+			stop()
+
+			// then
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			assert.GreaterOrEqual(t, len(lines), 1)
+			assert.Regexp(t, `^progress: count, 2.0 KiB read, 10 records decoded, 4 records counted, \d+ records/s$`, lines[0])
+			assert.True(t, stats.decodesPerRecord())
+		},
+		"should accept a nil receiver": func(t *testing.T) {
+			// given
+			var stats *pipelineStats
+			var output bytes.Buffer
+
+			// when
+			stats.phase("read")()
+			stats.addBytesRead(1)
+			stats.addRecordsDecoded(1)
+			stats.addRecordsCounted(1)
+			stats.reportProgress(&output, time.Millisecond)()
+			stats.printTimings(&output)
+
+			// then
+			assert.False(t, stats.decodesPerRecord())
+			assert.Equal(t, 0, output.Len())
+		},
+		"should count bytes read": func(t *testing.T) {
+			// given
+			stats := newPipelineStats()
+			reader := countingReader{strings.NewReader("recipes"), stats}
+
+			// when
+			reader.Read(make([]byte, 4))
+
+			// then
+			assert.Equal(t, int64(4), stats.bytesRead)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "3.0 GiB", formatBytes(3<<30))
+}
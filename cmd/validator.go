@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// limits from the fixtures data file specification
+const postcodeMaxLength int = 10
+const recipeMaxLength int = 100
+
+const maxInvalidRecordsDefault int = 100
+
+type validationReport struct {
+	RecordCount        int             `json:"record_count"`
+	InvalidRecordCount int             `json:"invalid_record_count"`
+	InvalidRecords     []invalidRecord `json:"invalid_records"`
+}
+
+type invalidRecord struct {
+	Index  int             `json:"index"`
+	Record json.RawMessage `json:"record"`
+	Errors []string        `json:"errors"`
+}
+
+// validateRecipeDelivery returns every way r breaks the fixtures data file specification.
+func validateRecipeDelivery(r recipeDelivery) []string {
+	errs := make([]string, 0)
+	if len(r.Postcode) == 0 {
+		errs = append(errs, "missing postcode")
+	} else if len(r.Postcode) > postcodeMaxLength {
+		errs = append(errs, fmt.Sprintf("postcode longer than %d chars", postcodeMaxLength))
+	}
+	if len(r.Recipe) == 0 {
+		errs = append(errs, "missing recipe")
+	} else if len(r.Recipe) > recipeMaxLength {
+		errs = append(errs, fmt.Sprintf("recipe longer than %d chars", recipeMaxLength))
+	}
+	if _, ok := scanDeliveryWindow(r.Delivery); !ok {
+		errs = append(errs, fmt.Sprintf("delivery %q is not formatted as \"{weekday} {h}AM - {h}PM\"", r.Delivery))
+	}
+	return errs
+}
+
+// validateFixtures checks every record of the fixtures data file, keeping up to maxInvalid invalid records
+// in the report. Records of the wrong type are reported as invalid, while malformed JSON fails the validation.
+func validateFixtures(r io.Reader, maxInvalid int) (validationReport, error) {
+	report := validationReport{InvalidRecords: make([]invalidRecord, 0)}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return report, err
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return report, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	token, err := decoder.Token()
+	if err != nil {
+		return report, err
+	}
+	if token != json.Delim('[') {
+		return report, errors.New("fixtures data must be an array of deliveries")
+	}
+	for i := 0; decoder.More(); i++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return report, fmt.Errorf("record %d: %v", i, err)
+		}
+		report.RecordCount++
+
+		var record recipeDelivery
+		var errs []string
+		if err := json.Unmarshal(raw, &record); err != nil {
+			errs = []string{err.Error()}
+		} else {
+			errs = validateRecipeDelivery(record)
+		}
+		if len(errs) == 0 {
+			continue
+		}
+		report.InvalidRecordCount++
+		if len(report.InvalidRecords) < maxInvalid {
+			report.InvalidRecords = append(report.InvalidRecords, invalidRecord{i, raw, errs})
+		}
+	}
+	_, err = decoder.Token()
+	return report, err
+}
+
+// runValidate writes the validation report to stdout, and exits with exitInvalid when there are bad records.
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	setUsage(flags, "validate [options]", "Checks every record of the fixtures data file and writes a JSON report of the bad ones to stdout.")
+	filePath := flags.String("file", "", "fixtures data file path (required)")
+	maxInvalid := flags.Int("max-invalid", maxInvalidRecordsDefault, "maximum number of invalid records listed in the report")
+	if err := flags.Parse(args); err != nil {
+		return usageError(err)
+	}
+	if len(*filePath) == 0 {
+		return usageError(errors.New("file is a required argument"))
+	}
+
+	file, err := os.Open(*filePath)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	defer file.Close()
+	report, err := validateFixtures(file, *maxInvalid)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+
+	printer := json.NewEncoder(os.Stdout)
+	if err := printer.Encode(report); err != nil {
+		log.Println(err)
+		return exitError
+	}
+	if report.InvalidRecordCount > 0 {
+		log.Printf("%d of %d records are invalid", report.InvalidRecordCount, report.RecordCount)
+		return exitInvalid
+	}
+	return exitOK
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRecipeDelivery(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should accept a valid record": func(t *testing.T) {
+			// when
+			errs := validateRecipeDelivery(recipeDelivery{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"})
+
+			// then
+			assert.Empty(t, errs)
+		},
+		"should report every error of an invalid record": func(t *testing.T) {
+			// when
+			errs := validateRecipeDelivery(recipeDelivery{Postcode: "12345678901", Recipe: "", Delivery: "Someday 13XM - 25YM"})
+
+			// then
+			assert.Equal(t, 3, len(errs))
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestValidateFixtures(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should report invalid records up to the maximum": func(t *testing.T) {
+			// given
+			fixtures := `[
+				{"postcode": "10120", "recipe": "Creamy Dill Chicken", "delivery": "Wednesday 10AM - 3PM"},
+				{"postcode": "", "recipe": "Creamy Dill Chicken", "delivery": "Wednesday 10AM - 3PM"},
+				{"postcode": 10120, "recipe": "Creamy Dill Chicken", "delivery": "Wednesday 10AM - 3PM"},
+				{"postcode": "10120", "recipe": "Creamy Dill Chicken", "delivery": "Wednesday"}
+			]`
+
+			// when
+			report, err := validateFixtures(strings.NewReader(fixtures), 2)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, 4, report.RecordCount)
+			assert.Equal(t, 3, report.InvalidRecordCount)
+			assert.Equal(t, 2, len(report.InvalidRecords))
+			assert.Equal(t, 1, report.InvalidRecords[0].Index)
+			assert.Equal(t, []string{"missing postcode"}, report.InvalidRecords[0].Errors)
+			assert.Equal(t, 2, report.InvalidRecords[1].Index)
+		},
+		"should fail on malformed JSON": func(t *testing.T) {
+			// when
+			_, errSyntax := validateFixtures(strings.NewReader(`[{"postcode": "10120"`), 10)
+			_, errObject := validateFixtures(strings.NewReader(`{"postcode": "10120"}`), 10)
+
+			// then
+			assert.Error(t, errSyntax)
+			assert.Error(t, errObject)
+		},
+		"should accept an empty file": func(t *testing.T) {
+			// when
+			report, err := validateFixtures(strings.NewReader(""), 10)
+
+			// then
+			assert.NoError(t, err)
+			assert.Equal(t, 0, report.RecordCount)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+const watchIntervalDefault time.Duration = time.Second
+const watchDebounceDefault time.Duration = 500 * time.Millisecond
+
+type fileWatcher struct {
+	path     string
+	interval time.Duration
+	debounce time.Duration
+}
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{
+		exists:  true,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+}
+
+// watch calls onChange once at start and then every time the watched file changes, until stop is closed.
+// Changes are only reported after the file stays untouched for the debounce period, so a file that is
+// still being written triggers a single call once the writer is done.
+func (w fileWatcher) watch(stop <-chan struct{}, onChange func()) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	last := statFile(w.path)
+	onChange()
+
+	pending := false
+	var changedAt time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			current := statFile(w.path)
+			if current != last {
+				last = current
+				pending = true
+				changedAt = now
+				continue
+			}
+			if pending && current.exists && now.Sub(changedAt) >= w.debounce {
+				pending = false
+				onChange()
+			}
+		}
+	}
+}
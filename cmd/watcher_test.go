@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileWatcher(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should call on start and once after the file settles": func(t *testing.T) {
+			// given
+			path := filepath.Join(t.TempDir(), "fixtures.json")
+			ioutil.WriteFile(path, []byte("[]"), 0644)
+			watcher := fileWatcher{
+				path:     path,
+				interval: 5 * time.Millisecond,
+				debounce: 20 * time.Millisecond,
+			}
+			calls := make(chan struct{}, 10)
+			stop := make(chan struct{})
+			done := make(chan struct{})
+
+			// when
+			go func() {
+				watcher.watch(stop, func() { calls <- struct{}{} })
+				close(done)
+			}()
+			<-calls
+			ioutil.WriteFile(path, []byte("[{"), 0644)
+			ioutil.WriteFile(path, []byte("[{}]"), 0644)
+			time.Sleep(100 * time.Millisecond)
+			close(stop)
+			<-done
+
+			// then
+			assert.Equal(t, 1, len(calls))
+		},
+		"should not call while the file is missing": func(t *testing.T) {
+			// given
+			path := filepath.Join(t.TempDir(), "missing.json")
+			watcher := fileWatcher{
+				path:     path,
+				interval: 5 * time.Millisecond,
+				debounce: 5 * time.Millisecond,
+			}
+			calls := make(chan struct{}, 10)
+			stop := make(chan struct{})
+			done := make(chan struct{})
+
+			// when
+			go func() {
+				watcher.watch(stop, func() { calls <- struct{}{} })
+				close(done)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			close(stop)
+			<-done
+
+			// then
+			assert.Equal(t, 1, len(calls))
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const narrowWindowDefault time.Duration = 3 * time.Hour
+
+// windowWidthAggregator counts delivery windows per width, per postcode, and per distinct window of the day.
+// Windows ending at 12AM end at midnight, and other windows ending before they start are left out.
+type windowWidthAggregator struct {
+	perPostcode map[postcodeWidth]int
+	perWindow   map[deliveryWindow]int
+	narrow      int
+}
+
+// postcodeWidth is a window width in minutes, for a postcode.
+type postcodeWidth struct {
+	postcode string
+	width    int
+}
+
+func newWindowWidthAggregator(options recipeCountOptions) aggregator {
+	narrow := options.narrowWindow
+	if narrow == 0 {
+		narrow = narrowWindowDefault
+	}
+	return &windowWidthAggregator{make(map[postcodeWidth]int), make(map[deliveryWindow]int), int(narrow.Minutes())}
+}
+
+func (a *windowWidthAggregator) add(r *deliveryRecord) {
+	w, ok := r.deliveryWindow()
+	if !ok {
+		return
+	}
+	width := w.end - w.start
+	if width <= 0 && w.end%minutesPerDay == 0 {
+		width += minutesPerDay
+	}
+	if width <= 0 {
+		return
+	}
+	a.perPostcode[postcodeWidth{r.Postcode, width}]++
+	a.perWindow[deliveryWindow{w.start % minutesPerDay, w.end % minutesPerDay}]++
+}
+
+func (a *windowWidthAggregator) merge(o aggregator) {
+	other := o.(*windowWidthAggregator)
+	for k, v := range other.perPostcode {
+		a.perPostcode[k] += v
+	}
+	for k, v := range other.perWindow {
+		a.perWindow[k] += v
+	}
+}
+
+func (a *windowWidthAggregator) result(response *recipeCountResponse) {
+	overall := make(map[int]int)
+	perPostcode := make(map[string]map[int]int)
+	for k, count := range a.perPostcode {
+		overall[k.width] += count
+		widths, exists := perPostcode[k.postcode]
+		if !exists {
+			widths = make(map[int]int)
+			perPostcode[k.postcode] = widths
+		}
+		widths[k.width] += count
+	}
+
+	report := &windowWidthReport{
+		Overall:        newWidthStats(overall, a.narrow),
+		PerPostcode:    make([]postcodeWidthStats, 0, len(perPostcode)),
+		CountPerWindow: make([]windowCount, 0, len(a.perWindow)),
+	}
+	for postcode, widths := range perPostcode {
+		report.PerPostcode = append(report.PerPostcode, postcodeWidthStats{postcode, newWidthStats(widths, a.narrow)})
+	}
+	sort.Slice(report.PerPostcode, func(i, j int) bool {
+		return report.PerPostcode[i].Postcode < report.PerPostcode[j].Postcode
+	})
+
+	windows := make([]deliveryWindow, 0, len(a.perWindow))
+	for w := range a.perWindow {
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		if windows[i].start != windows[j].start {
+			return windows[i].start < windows[j].start
+		}
+		return windows[i].end < windows[j].end
+	})
+	for _, w := range windows {
+		report.CountPerWindow = append(report.CountPerWindow, windowCount{groupKey{window: w}.value(groupByWindow), a.perWindow[w]})
+	}
+	response.WindowWidths = report
+}
+
+type windowWidthReport struct {
+	Overall        widthStats           `json:"overall"`
+	PerPostcode    []postcodeWidthStats `json:"per_postcode"`
+	CountPerWindow []windowCount        `json:"count_per_window"`
+}
+
+type postcodeWidthStats struct {
+	Postcode string `json:"postcode"`
+	widthStats
+}
+
+// widthStats describes window widths in hours, where narrow windows are at most as wide as -narrow-window.
+type widthStats struct {
+	WindowCount   int          `json:"window_count"`
+	MeanHours     float64      `json:"mean_hours"`
+	MedianHours   float64      `json:"median_hours"`
+	MinHours      float64      `json:"min_hours"`
+	MaxHours      float64      `json:"max_hours"`
+	NarrowCount   int          `json:"narrow_count"`
+	NarrowShare   float64      `json:"narrow_share"`
+	CountPerWidth []widthCount `json:"count_per_width"`
+}
+
+type widthCount struct {
+	Hours float64 `json:"hours"`
+	Count int     `json:"count"`
+}
+
+type windowCount struct {
+	Window string `json:"window"`
+	Count  int    `json:"count"`
+}
+
+// newWidthStats summarizes the windows counted per width in minutes.
+func newWidthStats(widths map[int]int, narrow int) widthStats {
+	stats := widthStats{CountPerWidth: make([]widthCount, 0, len(widths))}
+	sorted := make([]int, 0, len(widths))
+	total := 0
+	for width, count := range widths {
+		sorted = append(sorted, width)
+		stats.WindowCount += count
+		total += width * count
+		if width <= narrow {
+			stats.NarrowCount += count
+		}
+	}
+	if stats.WindowCount == 0 {
+		return stats
+	}
+	sort.Ints(sorted)
+
+	stats.MeanHours = float64(total) / float64(stats.WindowCount) / 60
+	stats.MinHours = float64(sorted[0]) / 60
+	stats.MaxHours = float64(sorted[len(sorted)-1]) / 60
+	stats.NarrowShare = float64(stats.NarrowCount) / float64(stats.WindowCount)
+
+	// the median is the middle window, or the mean of both middle ones
+	lower, upper := (stats.WindowCount-1)/2, stats.WindowCount/2
+	seen := 0
+	var lowerWidth, upperWidth int
+	for _, width := range sorted {
+		count := widths[width]
+		if seen <= lower && lower < seen+count {
+			lowerWidth = width
+		}
+		if seen <= upper && upper < seen+count {
+			upperWidth = width
+		}
+		seen += count
+		stats.CountPerWidth = append(stats.CountPerWidth, widthCount{float64(width) / 60, count})
+	}
+	stats.MedianHours = float64(lowerWidth+upperWidth) / 2 / 60
+	return stats
+}
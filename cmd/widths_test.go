@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowWidthAggregator(t *testing.T) {
+	input := []recipeDelivery{
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Wednesday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Creamy Dill Chicken", Delivery: "Monday 10AM - 3PM"},
+		{Postcode: "10120", Recipe: "Speedy Steak Fajitas", Delivery: "Wednesday 2PM - 4PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Sunday 10PM - 12AM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Sunday 5PM - 1PM"},
+		{Postcode: "10208", Recipe: "Speedy Steak Fajitas", Delivery: "Someday"},
+	}
+
+	tests := map[string]func(*testing.T){
+		"should describe window widths per postcode and overall across workers": func(t *testing.T) {
+			// given
+			options := recipeCountOptions{metrics: []string{"windows"}, narrowWindow: 3 * time.Hour}
+
+			// when
+			response := aggregateInTwoWorkers(t, options, input, 2)
+
+			// then
+			assert.Equal(t, widthStats{
+				WindowCount:   4,
+				MeanHours:     3.5,
+				MedianHours:   3.5,
+				MinHours:      2,
+				MaxHours:      5,
+				NarrowCount:   2,
+				NarrowShare:   0.5,
+				CountPerWidth: []widthCount{{2, 2}, {5, 2}},
+			}, response.WindowWidths.Overall)
+			assert.Equal(t, []postcodeWidthStats{
+				{"10120", widthStats{3, 4, 5, 2, 5, 1, 1.0 / 3, []widthCount{{2, 1}, {5, 2}}}},
+				{"10208", widthStats{1, 2, 2, 2, 2, 1, 1, []widthCount{{2, 1}}}},
+			}, response.WindowWidths.PerPostcode)
+			assert.Equal(t, []windowCount{
+				{"10AM - 3PM", 2},
+				{"2PM - 4PM", 1},
+				{"10PM - 12AM", 1},
+			}, response.WindowWidths.CountPerWindow)
+		},
+		"should only count windows at most as wide as the narrow window as narrow": func(t *testing.T) {
+			// when
+			stats := newWidthStats(map[int]int{90: 1, 180: 2, 240: 1}, 120)
+			empty := newWidthStats(map[int]int{}, 180)
+
+			// then
+			assert.Equal(t, 1, stats.NarrowCount)
+			assert.Equal(t, 0.25, stats.NarrowShare)
+			assert.Equal(t, 3.0, stats.MedianHours)
+			assert.Equal(t, 1.5, stats.MinHours)
+			assert.Equal(t, widthStats{CountPerWidth: []widthCount{}}, empty)
+		},
+	}
+
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}